@@ -1,32 +1,67 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
-	"github.com/jasonmoo/ghostmates"
-	"github.com/nlopes/slack"
 	"googlemaps.github.io/maps"
 )
 
+// tickInterval is how often Garcon checks on things like ordering deadlines
+const tickInterval = 15 * time.Second
+
 var g *Garcon
-var sb *slack.Client
-var rtm *slack.RTM
+var transport Transport
 var allowedChannels []string
 var errorEncounteredDoingSetup bool
 
-func init() {
-	allowedChannels = []string{"food", "bot-tester", "garcon_test"}
-	sb = slack.New(os.Getenv("GARCON_TOKEN"))
-	rtm = sb.NewRTM()
+// deliveryUpdates are changes to deliveries that providers told us about unprompted
+var deliveryUpdates chan *Delivery
+
+// setupSlack configures Garcon to take orders over Slack, using the real Maps and
+// Postmates backends as described by the config file at the given path
+func setupSlack(configPath string) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	allowedChannels = config.Channels
+	slackTransport := NewSlackTransport(config.Slack.Token)
+	transport = slackTransport
 
 	g = NewGarcon()
 	g.SelfName = "garcon"
-	g.debug = true
+	config.Apply(g)
+
+	store, err := NewFileSessionStore(config.StateDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.Sessions.Store = store
+	if err := g.Sessions.Restore(); err != nil {
+		log.Printf("I wasn't able to restore the sessions I had before:\n%v\n", err)
+	}
+	// records live in their own directory, so they're never mistaken for sessions
+	g.Spending, err = NewSpending(filepath.Join(config.StateDir, "records", "spending.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.Ledger, err = NewLedger(filepath.Join(config.StateDir, "records", "ledger.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.OrderHistory, err = NewOrderHistory(filepath.Join(config.StateDir, "records", "orders.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	users, err := sb.GetUsers()
+	patrons, err := transport.Patrons()
 	if err != nil {
 		errorEncounteredDoingSetup = true
 		if fmt.Sprintf("%v", err) == "Post https://slack.com/api/users.list: dial tcp: lookup slack.com: no such host" {
@@ -36,52 +71,78 @@ func init() {
 		log.Printf("Error retrieving users:\n%v\n", err)
 	}
 
-	g.Patrons = make(map[string]slack.User)
-	for _, u := range users {
-		g.Patrons[u.ID] = u
+	g.Patrons = make(map[string]Patron)
+	for _, p := range patrons {
+		g.Patrons[p.ID] = p
 	}
 	g.FindBotSlackID()
 
-	channels, err := sb.GetChannels(true)
+	channels, err := transport.ChannelIDs(allowedChannels)
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, ch := range channels {
-		if sliceContainsString(ch.Name, allowedChannels) {
-			g.AllowedChannels = append(g.AllowedChannels, ch.ID)
-		}
+	g.AllowedChannels = append(g.AllowedChannels, channels...)
+
+	if len(config.HTTP.Address) > 0 {
+		serveCallbacks(config, slackTransport)
 	}
 
-	customerID := os.Getenv("POSTMATES_CUSTOMER_ID")
-	sandboxKey := os.Getenv("POSTMATES_API_TOKEN")
-	duration, err := time.ParseDuration("1m")
+	g.DeliveryProvider, err = NewDeliveryProvider(config.Delivery.Provider, config.Postmates.CustomerID, config.Postmates.APIKey)
 	if err != nil {
 		log.Fatal(err)
 	}
-	g.PostmatesClient = ghostmates.NewClient(customerID, sandboxKey, duration)
-	g.OrderDestination = ghostmates.NewDeliverySpot(os.Getenv("GARCON_DESTINATION_NAME"), os.Getenv("GARCON_DESTINATION_ADDRESS"), os.Getenv("GARCON_DESTINATION_NUMBER"))
+	if pp, ok := g.DeliveryProvider.(*PostmatesProvider); ok {
+		pp.HTTPClient.Timeout = config.Delivery.Timeout
+	}
+
+	if len(config.Search.RestaurantsFile) > 0 {
+		g.RestaurantFinder, err = NewStaticRestaurantFinder(config.Search.RestaurantsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	apiKey := os.Getenv("GOOGLE_MAPS_API_KEY")
-	c, err := maps.NewClient(maps.WithAPIKey(apiKey))
+	c, err := maps.NewClient(maps.WithAPIKey(config.GoogleMaps.APIKey))
 	if err != nil {
 		log.Fatalf("fatal error establishing client: %s", err)
 	}
-	g.GoogleMapsClient = c
+	finder := NewGoogleRestaurantFinder(c)
+	finder.Radius = config.Search.RadiusMeters
+	g.RestaurantFinder = finder
 }
 
-func makeIDToUserMap(in []slack.User) map[string]slack.User {
-	users := make(map[string]slack.User)
-	for _, u := range in {
-		users[u.ID] = u
+// serveCallbacks listens for the requests Slack makes when people interact with
+// Garcon's messages or use the /garcon command, and for delivery webhooks
+func serveCallbacks(config *Config, st *SlackTransport) {
+	mux := http.NewServeMux()
+	mux.Handle("/slack/interactions", st.InteractionHandler(config.Slack.SigningSecret))
+	mux.Handle("/slack/commands", st.SlashCommandHandler(config.Slack.SigningSecret))
+	if len(config.Postmates.WebhookSecret) > 0 {
+		deliveryUpdates = make(chan *Delivery)
+		mux.Handle("/postmates/webhook", PostmatesWebhookHandler(config.Postmates.WebhookSecret, deliveryUpdates))
 	}
-	return users
+
+	go func() {
+		log.Fatal(http.ListenAndServe(config.HTTP.Address, mux))
+	}()
+	log.Printf("I'm listening for callbacks on %v", config.HTTP.Address)
 }
 
-func handleMessage(m slack.Msg) {
-	responses := g.RespondToMessage(m)
+func handleMessage(m Message) {
+	sendResponses(g.RespondToMessage(m))
+}
+
+func sendResponses(responses []OutgoingMessage) {
 	for _, response := range responses {
+		response = g.Threaded(response)
 		if len(response.Text) > 0 && sliceContainsString(response.Channel, g.AllowedChannels) {
-			rtm.SendMessage(rtm.NewOutgoingMessage(response.Text, response.Channel))
+			timestamp, err := transport.Send(response)
+			if err != nil {
+				log.Printf("I couldn't send this message\n\t%v\nbecause of this error:\n\t%v\n", response.Text, err)
+				continue
+			}
+			g.ResponseSent(response, timestamp)
 		} else {
 			log.Printf("I couldn't send this message\n\t%v\n", response.Text)
 		}
@@ -89,20 +150,47 @@ func handleMessage(m slack.Msg) {
 }
 
 func main() {
+	configPath := flag.String("config", defaultConfigPath, "path to the config file (or set GARCON_CONFIG)")
+	flag.Parse()
+	if envPath := os.Getenv("GARCON_CONFIG"); len(envPath) > 0 && !flagWasSet("config") {
+		*configPath = envPath
+	}
+
+	if flag.Arg(0) == "repl" {
+		setupRepl(flag.Args()[1:])
+	} else {
+		setupSlack(*configPath)
+	}
+
 	if !errorEncounteredDoingSetup {
-		go rtm.ManageConnection()
+		transport.Start()
+		messages := transport.Messages()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
 		for {
 			select {
-			case msg := <-rtm.IncomingEvents:
-				switch ev := msg.Data.(type) {
+			case m, ok := <-messages:
+				if !ok {
+					return
+				}
+				handleMessage(m)
 
-				case *slack.MessageEvent:
-					handleMessage(ev.Msg)
+			case d := <-deliveryUpdates:
+				sendResponses(g.DeliveryUpdated(d))
 
-				case *slack.RTMError:
-					log.Printf("I encountered a Slack related error: %s\n", ev.Error())
-				}
+			case now := <-ticker.C:
+				sendResponses(g.Tick(now))
 			}
 		}
 	}
 }
+
+func flagWasSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	restaurantChoicePattern   = "^(number |#)?(?P<number>[0-9]+)$"
+	restaurantOrdinalPattern  = "^(the )?(?P<ordinal>first|second|third|fourth|fifth)( one)?$"
+	noneOfTheseChoicesPattern = "none of (those|these|them)"
+	metersPerMile             = 1609.344
+)
+
+// choiceReactions are the emoji people can react with to pick a restaurant by number
+var choiceReactions = []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+
+var choiceOrdinals = []string{"first", "second", "third", "fourth", "fifth"}
+
+// restaurantChoice returns which of the candidate restaurants a message picked, counting
+// from zero, or -1 if it didn't pick one
+func restaurantChoice(s *Session, m Message) int {
+	choice := -1
+	if len(m.Action) > 0 {
+		if n, err := strconv.Atoi(m.Action); err == nil {
+			choice = n - 1
+		}
+	} else if len(m.Reaction) > 0 {
+		for i, r := range choiceReactions {
+			if m.Reaction == r {
+				choice = i
+			}
+		}
+	} else {
+		choice = numberChosen(m.Text)
+	}
+
+	if choice < 0 || choice >= len(s.Candidates) {
+		return -1
+	}
+	return choice
+}
+
+// numberChosen returns which of a numbered list of choices someone picked by saying
+// something like "2" or "the second one", counting from zero, or -1 if they didn't
+func numberChosen(text string) int {
+	if match, err := findElementsInString(restaurantChoicePattern, []string{"number"}, cleanString(text)); err == nil {
+		n, _ := strconv.Atoi(match["number"])
+		return n - 1
+	}
+	if match, err := findElementsInString(restaurantOrdinalPattern, []string{"ordinal"}, cleanString(text)); err == nil {
+		for i, o := range choiceOrdinals {
+			if strings.ToLower(match["ordinal"]) == o {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func describeDistance(meters float64) string {
+	if meters <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%.1f mi away)", meters/metersPerMile)
+}
+
+func (g *Garcon) chooseAmongRestaurants(s *Session, m Message, restaurants []Restaurant) []OutgoingMessage {
+	s.Candidates = restaurants
+	s.Stage = "choosing"
+
+	lines := []string{fmt.Sprintf("I found a few places matching \"%v\". Which one did you mean, @%v?", s.RequestedRestaurant, g.Patrons[s.InterlocutorID].Name)}
+	for i, r := range restaurants {
+		lines = append(lines, fmt.Sprintf("%v. %v, %v%v", i+1, r.Name, r.Address, describeDistance(r.Distance)))
+	}
+	lines = append(lines, "Say the number of the one you'd like, react to this message with its number, or say \"none of those\".")
+
+	question := OutgoingMessage{Channel: m.Channel, Text: strings.Join(lines, "\n")}
+	if g.Interactive {
+		for i, r := range restaurants {
+			question.Options = append(question.Options, Choice{Text: fmt.Sprintf("%v. %v", i+1, r.Name), Value: strconv.Itoa(i + 1)})
+		}
+		question.Buttons = []Choice{Choice{Text: "None of these", Value: noneOfTheseAction}}
+	}
+	return []OutgoingMessage{question}
+}
+
+func (g *Garcon) settleOnRestaurant(s *Session, m Message, r Restaurant) []OutgoingMessage {
+	s.ActualRestaurant = &r
+	s.Candidates = nil
+	log.Printf("I decided to go with the following restaurant:\n%v\n%v\n", s.ActualRestaurant.Name, s.ActualRestaurant.Address)
+
+	t := fmt.Sprintf("Okay, what would everyone like from %v?", s.ActualRestaurant.Name)
+	if g.Menus != nil {
+		menu, err := g.Menus.MenuFor(r.PlaceID)
+		if err != nil {
+			log.Printf("I wasn't able to load the menu for %v, so I'll take orders without it:\n\t%v\n", r.Name, err)
+		}
+		if menu != nil {
+			s.Menu = menu
+			t = fmt.Sprintf("%v I have their menu, so I'll check what you order against it.", t)
+		}
+	}
+	s.Stage = "ordering"
+
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+func (g *Garcon) restaurantChosen(s *Session, m Message) []OutgoingMessage {
+	choice := restaurantChoice(s, m)
+	if choice < 0 {
+		return g.genericHelpResponse(s, m)
+	}
+	return g.settleOnRestaurant(s, m, s.Candidates[choice])
+}
+
+func (g *Garcon) noRestaurantChosen(s *Session, m Message) []OutgoingMessage {
+	s.Candidates = nil
+	s.RequestedRestaurant = ""
+	s.Stage = "prompted"
+
+	t := "Okay, where would you like to order from instead?"
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+func messageIsReaction(m Message) bool {
+	return len(m.Reaction) > 0
+}
+
+func messageIsAction(m Message) bool {
+	return len(m.Action) > 0
+}
+
+func noneOfTheseChoices(m Message) bool {
+	return responseIsNegative(m.Text) || stringFitsPattern(noneOfTheseChoicesPattern, m.Text)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// slashCommands translate each /garcon subcommand into whatever someone would have said
+// to Garcon to do the same thing, so that both go through the same state machine
+var slashCommands = map[string]func(g *Garcon, s *Session, args string) []string{
+	"start": func(g *Garcon, s *Session, args string) []string {
+		said := []string{}
+		if s.Stage == "uninitiated" {
+			said = append(said, "oh, garçon?")
+		}
+		return append(said, fmt.Sprintf("We'd like to order from %v", args))
+	},
+	"add": func(g *Garcon, s *Session, args string) []string {
+		return []string{fmt.Sprintf("<@%v> I'll have %v", g.SelfID, args)}
+	},
+	"status": func(g *Garcon, s *Session, args string) []string {
+		return []string{"what's our order look like?"}
+	},
+	"ready": func(g *Garcon, s *Session, args string) []string {
+		return []string{"I think we're ready"}
+	},
+	"cancel": func(g *Garcon, s *Session, args string) []string {
+		return []string{fmt.Sprintf("<@%v> go away", g.SelfID)}
+	},
+}
+
+var slashCommandUsage = map[string]string{
+	"start":  "/garcon start <restaurant>",
+	"add":    "/garcon add <item>",
+	"status": "/garcon status",
+	"ready":  "/garcon ready",
+	"cancel": "/garcon cancel",
+}
+
+func messageIsCommand(m Message) bool {
+	return len(m.Command) > 0
+}
+
+// respondToCommand runs a /garcon command through the session's state machine as
+// though it had been said in the channel
+func (g *Garcon) respondToCommand(m Message) (responses []OutgoingMessage) {
+	fields := strings.SplitN(strings.TrimSpace(m.Command), " ", 2)
+	name := strings.ToLower(fields[0])
+	args := ""
+	if len(fields) > 1 {
+		args = strings.TrimSpace(fields[1])
+	}
+
+	translate, ok := slashCommands[name]
+	if !ok {
+		return g.commandHelpResponse(m, name)
+	}
+
+	s := g.Sessions.SessionFor(m.Channel, m.ThreadTimestamp)
+	if len(m.ThreadTimestamp) == 0 && name != "start" {
+		// new orders start in the channel, and get threads of their own, but everything
+		// else is about whichever order's going on
+		s = g.Sessions.ActiveSessionIn(m.Channel)
+	}
+	if name == "start" && s.Stage != "uninitiated" && s.Stage != "prompted" {
+		return about(s, g.orderUnderwayResponse(s, m))
+	}
+	for _, text := range translate(g, s, args) {
+		said := m
+		said.Command = ""
+		said.Text = text
+		said.ThreadTimestamp = s.ThreadTimestamp
+		responses = append(responses, g.RespondToMessage(said)...)
+	}
+
+	if len(responses) == 0 {
+		if g.debug {
+			log.Printf("The /garcon %v command didn't mean anything in the %v stage", name, s.Stage)
+		}
+		t := fmt.Sprintf("I'm sorry, @%v, I can't do that right now. Try \"/garcon help\" to see what you can do.", g.Patrons[m.User].Name)
+		return about(s, []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}})
+	}
+	return
+}
+
+// orderUnderwayResponse explains which order is in the way of starting a new one
+func (g *Garcon) orderUnderwayResponse(s *Session, m Message) []OutgoingMessage {
+	order := fmt.Sprintf("@%v's order", g.Patrons[s.InterlocutorID].Name)
+	if restaurant := s.ActualRestaurant.Name; len(restaurant) > 0 {
+		order = fmt.Sprintf("%v from %v", order, restaurant)
+	} else if len(s.RequestedRestaurant) > 0 {
+		order = fmt.Sprintf("%v from %v", order, s.RequestedRestaurant)
+	}
+	t := fmt.Sprintf("I'm sorry, @%v, %v is still going on here, so I can't start another until it's done or canceled.", g.Patrons[m.User].Name, order)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+func (g *Garcon) commandHelpResponse(m Message, name string) []OutgoingMessage {
+	usage := []string{}
+	for _, u := range slashCommandUsage {
+		usage = append(usage, u)
+	}
+	sort.Strings(usage)
+	usage = append(usage, "/garcon help")
+
+	t := "Here's what you can ask me to do with /garcon:"
+	if len(name) > 0 && name != "help" {
+		t = fmt.Sprintf("I'm sorry, @%v, I don't know how to %v. %v", g.Patrons[m.User].Name, name, t)
+	}
+	sep := "\n • "
+	t = fmt.Sprintf("%v%v%v", t, sep, strings.Join(usage, sep))
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlashCommandsDriveTheOrder(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.InterlocutorID = ""
+
+	m.Command = "start Gary's Racoon Hut"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "Hi, @brainfart! Would you like to place an order?", messages[0].Text)
+	assert.Equal(t, "Okay, what would everyone like from Gary's Racoon Hut?", messages[1].Text)
+	assert.Equal(t, "ordering", s.Stage)
+
+	m.Command = "add 2 tacos (no onions)"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added 2x tacos (no onions) to your order.", messages[0].Text)
+
+	m.Command = "status"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\nBrainfart: 2x tacos (no onions)\n```", messages[0].Text)
+
+	m.Command = "ready"
+	g.RespondToMessage(m)
+	assert.Equal(t, "confirmation", s.Stage)
+
+	m.Command = "cancel"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Very well then, I'll disappear for now!", messages[0].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+}
+
+func TestSlashCommandHelp(t *testing.T) {
+	g, _, m := returnGarconAndEmptyMessage()
+
+	m.Command = "help"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Here's what you can ask me to do with /garcon:\n • /garcon add <item>\n • /garcon cancel\n • /garcon ready\n • /garcon start <restaurant>\n • /garcon status\n • /garcon help", messages[0].Text)
+
+	m.Command = "dance"
+	messages = g.RespondToMessage(m)
+	assert.Contains(t, messages[0].Text, "I'm sorry, @brainfart, I don't know how to dance.")
+
+	m.Command = "add a banana"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, I can't do that right now. Try \"/garcon help\" to see what you can do.", messages[0].Text)
+}
+
+func TestSlashCommandsFindTheOrderInItsThread(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
+	g.Sessions.MoveToThread(s, "1234.5678")
+
+	m.Command = "add a banana"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added 1x banana to your order.", messages[0].Text)
+	assert.Equal(t, "1234.5678", g.Threaded(messages[0]).ThreadTimestamp)
+}
+
+func TestSlashCommandsStartOrdersAlongsideThreadedOnes(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	s.InterlocutorID = m.User
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+
+	m.Command = "start Chili's"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, @brainfart's order from Gary's Racoon Hut is still going on here, so I can't start another until it's done or canceled.", messages[0].Text)
+	assert.Equal(t, "Gary's Racoon Hut", s.ActualRestaurant.Name)
+
+	g.Sessions.MoveToThread(s, "1234.5678")
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Hi, @brainfart! Would you like to place an order?", messages[0].Text)
+	assert.Equal(t, "Gary's Racoon Hut", s.ActualRestaurant.Name, "the threaded order should be left alone")
+	assert.Equal(t, "ordering", g.Sessions.SessionFor(m.Channel, "").Stage)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultConfigPath = "garcon.yml"
+
+// Config is everything about Garcon that differs from one office to the next. It's
+// read from a YAML file, and any of it can be overridden by environment variables.
+type Config struct {
+	Debug    bool     `yaml:"debug"`
+	StateDir string   `yaml:"state_dir"`
+	Channels []string `yaml:"channels"`
+
+	Slack struct {
+		Token         string `yaml:"token"`
+		SigningSecret string `yaml:"signing_secret"`
+	} `yaml:"slack"`
+
+	HTTP struct {
+		Address string `yaml:"address"`
+	} `yaml:"http"`
+
+	Destination struct {
+		Name    string `yaml:"name"`
+		Address string `yaml:"address"`
+		Phone   string `yaml:"phone"`
+	} `yaml:"destination"`
+
+	Delivery struct {
+		Provider     string        `yaml:"provider"`
+		Attempts     int           `yaml:"attempts"`
+		Backoff      time.Duration `yaml:"backoff"`
+		Timeout      time.Duration `yaml:"timeout"`
+		PollInterval time.Duration `yaml:"poll_interval"`
+	} `yaml:"delivery"`
+
+	Postmates struct {
+		CustomerID    string `yaml:"customer_id"`
+		APIKey        string `yaml:"api_key"`
+		WebhookSecret string `yaml:"webhook_secret"`
+	} `yaml:"postmates"`
+
+	Search struct {
+		RadiusMeters    uint   `yaml:"radius_meters"`
+		RestaurantsFile string `yaml:"restaurants_file"`
+	} `yaml:"search"`
+
+	Menus struct {
+		Dir string `yaml:"dir"`
+	} `yaml:"menus"`
+
+	GoogleMaps struct {
+		APIKey string `yaml:"api_key"`
+	} `yaml:"google_maps"`
+
+	// Limits are in whole units of Currency, like dollars, with zero meaning no limit
+	Limits struct {
+		PerOrder      float64 `yaml:"per_order"`
+		PerPerson     float64 `yaml:"per_person"`
+		MonthlyBudget float64 `yaml:"monthly_budget"`
+		Currency      string  `yaml:"currency"`
+	} `yaml:"limits"`
+
+	// Costs are how the extras on an order are shared out, with rates in percent
+	Costs struct {
+		TaxRate          float64 `yaml:"tax_rate"`
+		TipRate          float64 `yaml:"tip_rate"`
+		DeliveryFeeSplit string  `yaml:"delivery_fee_split"`
+	} `yaml:"costs"`
+
+	Roles struct {
+		Default      string   `yaml:"default"`
+		Admins       []string `yaml:"admins"`
+		Orderers     []string `yaml:"orderers"`
+		Participants []string `yaml:"participants"`
+	} `yaml:"roles"`
+
+	DeadlineReminders []time.Duration          `yaml:"deadline_reminders"`
+	IdleTimeouts      map[string]time.Duration `yaml:"idle_timeouts"`
+}
+
+// envOverrides maps environment variables to the config values they replace
+var envOverrides = map[string]func(c *Config, v string) error{
+	"GARCON_DEBUG": func(c *Config, v string) (err error) {
+		c.Debug, err = strconv.ParseBool(v)
+		return
+	},
+	"GARCON_STATE_DIR": func(c *Config, v string) error {
+		c.StateDir = v
+		return nil
+	},
+	"GARCON_CHANNELS": func(c *Config, v string) error {
+		c.Channels = nil
+		for _, ch := range strings.Split(v, ",") {
+			if ch = strings.TrimSpace(ch); len(ch) > 0 {
+				c.Channels = append(c.Channels, ch)
+			}
+		}
+		return nil
+	},
+	"GARCON_TOKEN": func(c *Config, v string) error {
+		c.Slack.Token = v
+		return nil
+	},
+	"SLACK_SIGNING_SECRET": func(c *Config, v string) error {
+		c.Slack.SigningSecret = v
+		return nil
+	},
+	"GARCON_HTTP_ADDRESS": func(c *Config, v string) error {
+		c.HTTP.Address = v
+		return nil
+	},
+	"GARCON_DESTINATION_NAME": func(c *Config, v string) error {
+		c.Destination.Name = v
+		return nil
+	},
+	"GARCON_DESTINATION_ADDRESS": func(c *Config, v string) error {
+		c.Destination.Address = v
+		return nil
+	},
+	"GARCON_DESTINATION_NUMBER": func(c *Config, v string) error {
+		c.Destination.Phone = v
+		return nil
+	},
+	"GARCON_DELIVERY_PROVIDER": func(c *Config, v string) error {
+		c.Delivery.Provider = v
+		return nil
+	},
+	"POSTMATES_CUSTOMER_ID": func(c *Config, v string) error {
+		c.Postmates.CustomerID = v
+		return nil
+	},
+	"POSTMATES_API_TOKEN": func(c *Config, v string) error {
+		c.Postmates.APIKey = v
+		return nil
+	},
+	"POSTMATES_WEBHOOK_SECRET": func(c *Config, v string) error {
+		c.Postmates.WebhookSecret = v
+		return nil
+	},
+	"GARCON_SEARCH_RADIUS": func(c *Config, v string) error {
+		radius, err := strconv.ParseUint(v, 10, 32)
+		c.Search.RadiusMeters = uint(radius)
+		return err
+	},
+	"GARCON_RESTAURANTS_FILE": func(c *Config, v string) error {
+		c.Search.RestaurantsFile = v
+		return nil
+	},
+	"GARCON_MENUS_DIR": func(c *Config, v string) error {
+		c.Menus.Dir = v
+		return nil
+	},
+	"GOOGLE_MAPS_API_KEY": func(c *Config, v string) error {
+		c.GoogleMaps.APIKey = v
+		return nil
+	},
+}
+
+// NewConfig returns a Config with everything that has a sensible default filled in
+func NewConfig() *Config {
+	c := &Config{StateDir: "garcon-state"}
+	c.Delivery.Provider = "postmates"
+	c.Delivery.Attempts = 3
+	c.Delivery.Backoff = time.Second
+	c.Delivery.Timeout = 10 * time.Second
+	c.Delivery.PollInterval = time.Minute
+	c.Search.RadiusMeters = 10000
+	c.Limits.Currency = "usd"
+	c.Costs.DeliveryFeeSplit = "even"
+	return c
+}
+
+// LoadConfig reads the config file at the given path, applies any environment variable
+// overrides, and validates the result. A missing file is only an error if it isn't the
+// default one, so Garcon can still be configured entirely from the environment.
+func LoadConfig(path string) (*Config, error) {
+	c := NewConfig()
+
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.UnmarshalStrict(b, c); err != nil {
+			return nil, fmt.Errorf("unable to read config file %v: %v", path, err)
+		}
+	case os.IsNotExist(err) && path == defaultConfigPath:
+	default:
+		return nil, fmt.Errorf("unable to read config file %v: %v", path, err)
+	}
+
+	for name, override := range envOverrides {
+		if v, ok := os.LookupEnv(name); ok {
+			if err := override(c, v); err != nil {
+				return nil, fmt.Errorf("unable to use %v from the environment: %v", name, err)
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("config from %v is invalid:%v", path, err)
+	}
+	return c, nil
+}
+
+type configErrors []string
+
+func (ce configErrors) Error() string {
+	return "\n - " + strings.Join(ce, "\n - ")
+}
+
+// Validate makes sure the config has everything Garcon needs to run
+func (c *Config) Validate() error {
+	problems := configErrors{}
+	require := func(value, key, env string) {
+		if len(strings.TrimSpace(value)) == 0 {
+			problems = append(problems, fmt.Sprintf("%v is required (or set %v)", key, env))
+		}
+	}
+
+	require(c.Slack.Token, "slack.token", "GARCON_TOKEN")
+	require(c.Destination.Name, "destination.name", "GARCON_DESTINATION_NAME")
+	require(c.Destination.Address, "destination.address", "GARCON_DESTINATION_ADDRESS")
+	require(c.Destination.Phone, "destination.phone", "GARCON_DESTINATION_NUMBER")
+	if (len(c.HTTP.Address) > 0) != (len(c.Slack.SigningSecret) > 0) {
+		problems = append(problems, "http.address and slack.signing_secret have to be set together for buttons to work (or set GARCON_HTTP_ADDRESS and SLACK_SIGNING_SECRET)")
+	}
+	if len(c.Channels) == 0 {
+		problems = append(problems, "channels needs at least one channel for Garcon to take orders in (or set GARCON_CHANNELS)")
+	}
+
+	switch c.Delivery.Provider {
+	case "postmates":
+		require(c.Postmates.CustomerID, "postmates.customer_id", "POSTMATES_CUSTOMER_ID")
+		require(c.Postmates.APIKey, "postmates.api_key", "POSTMATES_API_TOKEN")
+	case "pickup":
+	default:
+		problems = append(problems, fmt.Sprintf("delivery.provider must be postmates or pickup, not %q", c.Delivery.Provider))
+	}
+	if c.Delivery.Attempts < 1 {
+		problems = append(problems, "delivery.attempts must be at least 1")
+	}
+	if c.Delivery.Timeout <= 0 {
+		problems = append(problems, "delivery.timeout must be longer than nothing")
+	}
+	if c.Delivery.PollInterval <= 0 {
+		problems = append(problems, "delivery.poll_interval must be longer than nothing")
+	}
+	if len(c.Postmates.WebhookSecret) > 0 && len(c.HTTP.Address) == 0 {
+		problems = append(problems, "postmates.webhook_secret needs http.address to be set, so Garcon can listen for webhooks (or set GARCON_HTTP_ADDRESS)")
+	}
+
+	if len(c.Search.RestaurantsFile) == 0 {
+		require(c.GoogleMaps.APIKey, "google_maps.api_key", "GOOGLE_MAPS_API_KEY")
+		if c.Search.RadiusMeters == 0 {
+			problems = append(problems, "search.radius_meters must be greater than zero")
+		}
+	}
+
+	if len(c.Menus.Dir) > 0 {
+		if info, err := os.Stat(c.Menus.Dir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("menus.dir has to be a directory, and %v isn't one (or set GARCON_MENUS_DIR)", c.Menus.Dir))
+		}
+	}
+
+	if c.Limits.PerOrder < 0 || c.Limits.PerPerson < 0 || c.Limits.MonthlyBudget < 0 {
+		problems = append(problems, "limits can't be negative")
+	}
+
+	if c.Costs.TaxRate < 0 || c.Costs.TipRate < 0 {
+		problems = append(problems, "costs.tax_rate and costs.tip_rate can't be negative")
+	}
+	if c.Costs.DeliveryFeeSplit != "even" && c.Costs.DeliveryFeeSplit != "proportional" {
+		problems = append(problems, fmt.Sprintf("costs.delivery_fee_split must be even or proportional, not %q", c.Costs.DeliveryFeeSplit))
+	}
+
+	if _, ok := roleNames[c.Roles.Default]; !ok && len(c.Roles.Default) > 0 {
+		problems = append(problems, fmt.Sprintf("roles.default must be admin, orderer or participant, not %q", c.Roles.Default))
+	}
+
+	for _, reminder := range c.DeadlineReminders {
+		if reminder <= 0 {
+			problems = append(problems, "deadline_reminders must all be longer than nothing")
+			break
+		}
+	}
+
+	for stage := range c.IdleTimeouts {
+		if !sliceContainsString(stage, []string{"prompted", "choosing", "ordering", "confirmation", "approval", "dispatched"}) {
+			problems = append(problems, fmt.Sprintf("idle_timeouts has a timeout for %q, which isn't a stage that can time out", stage))
+		}
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// Interactive returns whether Garcon can hear about clicks on its buttons and menus
+func (c *Config) Interactive() bool {
+	return len(c.HTTP.Address) > 0 && len(c.Slack.SigningSecret) > 0
+}
+
+// Apply configures Garcon's behavior according to the config
+func (c *Config) Apply(g *Garcon) {
+	g.debug = c.Debug
+	g.Interactive = c.Interactive()
+	g.DeliveryAttempts = c.Delivery.Attempts
+	g.DeliveryBackoff = c.Delivery.Backoff
+	g.DeliveryTimeout = c.Delivery.Timeout
+	g.DeliveryPollInterval = c.Delivery.PollInterval
+	g.OrderDestination = NewDeliverySpot(c.Destination.Name, c.Destination.Address, c.Destination.Phone)
+	if len(c.DeadlineReminders) > 0 {
+		// reminders go out in order, so the longest has to come first whatever order
+		// they were written in
+		g.DeadlineReminders = append([]time.Duration{}, c.DeadlineReminders...)
+		sort.Slice(g.DeadlineReminders, func(i, j int) bool { return g.DeadlineReminders[i] > g.DeadlineReminders[j] })
+	}
+	for stage, timeout := range c.IdleTimeouts {
+		g.IdleTimeouts[stage] = timeout
+	}
+	if len(c.Menus.Dir) > 0 {
+		g.Menus = &MenuLibrary{Dir: c.Menus.Dir}
+	}
+	g.Limits = SpendingLimits{
+		PerOrder:      cents(c.Limits.PerOrder),
+		PerPerson:     cents(c.Limits.PerPerson),
+		MonthlyBudget: cents(c.Limits.MonthlyBudget),
+		Currency:      c.Limits.Currency,
+	}
+	g.Costs = CostSplit{
+		TaxRate:          c.Costs.TaxRate / 100,
+		TipRate:          c.Costs.TipRate / 100,
+		ProportionalFees: c.Costs.DeliveryFeeSplit == "proportional",
+	}
+	c.applyRoles(g)
+}
+
+// cents converts whole units of currency into the smallest ones
+func cents(amount float64) int {
+	return int(math.Round(amount * 100))
+}
+
+// applyRoles gives Garcon the configured roles. Without any, everyone's an orderer,
+// and once some people are given roles, everyone else is just a participant unless
+// the config says otherwise.
+func (c *Config) applyRoles(g *Garcon) {
+	assigned := map[Role][]string{
+		ParticipantRole: c.Roles.Participants,
+		OrdererRole:     c.Roles.Orderers,
+		AdminRole:       c.Roles.Admins,
+	}
+	for role, members := range assigned {
+		for _, member := range members {
+			// groups are often written the way they're mentioned, like @lunch-crew
+			key := strings.ToLower(strings.TrimPrefix(member, "@"))
+			if r, ok := g.Roles[key]; !ok || role > r {
+				g.Roles[key] = role
+			}
+		}
+	}
+
+	switch {
+	case len(c.Roles.Default) > 0:
+		g.DefaultRole = roleNames[c.Roles.Default]
+	case len(g.Roles) > 0:
+		g.DefaultRole = ParticipantRole
+	default:
+		g.DefaultRole = OrdererRole
+	}
+}
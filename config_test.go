@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+channels: [food, lunch]
+slack:
+  token: xoxb-1234
+destination:
+  name: The Office
+  address: 1 Main St
+  phone: 555-555-5555
+delivery:
+  provider: pickup
+  backoff: 5s
+search:
+  restaurants_file: restaurants.json
+idle_timeouts:
+  ordering: 30m
+deadline_reminders: [2m, 15m, 5m]
+limits:
+  per_person: 12.50
+`
+
+func writeTestConfig(t *testing.T, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "garcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "garcon.yml")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadConfig(t *testing.T) {
+	path, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	c, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"food", "lunch"}, c.Channels)
+	assert.Equal(t, "garcon-state", c.StateDir, "defaults should survive loading a file that doesn't mention them")
+	assert.Equal(t, 5*time.Second, c.Delivery.Backoff)
+	assert.Equal(t, 3, c.Delivery.Attempts)
+	assert.Equal(t, 10*time.Second, c.Delivery.Timeout)
+
+	g := NewGarcon()
+	c.Apply(g)
+	assert.Equal(t, 30*time.Minute, g.IdleTimeouts["ordering"])
+	assert.Equal(t, 15*time.Minute, g.IdleTimeouts["prompted"])
+	assert.Equal(t, []time.Duration{15 * time.Minute, 5 * time.Minute, 2 * time.Minute}, g.DeadlineReminders, "reminders should be longest first")
+	assert.Equal(t, "1 Main St", g.OrderDestination.Address)
+	assert.Equal(t, SpendingLimits{PerPerson: 1250, Currency: "usd"}, g.Limits)
+	assert.False(t, g.debug)
+}
+
+func TestLoadConfigWithEnvironmentOverrides(t *testing.T) {
+	path, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	os.Setenv("GARCON_CHANNELS", "snacks, drinks")
+	os.Setenv("GARCON_DEBUG", "true")
+	defer os.Unsetenv("GARCON_CHANNELS")
+	defer os.Unsetenv("GARCON_DEBUG")
+
+	c, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"snacks", "drinks"}, c.Channels)
+	assert.True(t, c.Debug)
+
+	os.Setenv("GARCON_DEBUG", "sometimes")
+	_, err = LoadConfig(path)
+	assert.Contains(t, err.Error(), "GARCON_DEBUG")
+}
+
+func TestLoadConfigReportsEverythingMissing(t *testing.T) {
+	path, cleanup := writeTestConfig(t, "delivery:\n  provider: postmates\n")
+	defer cleanup()
+
+	_, err := LoadConfig(path)
+	assert.NotNil(t, err)
+	for _, key := range []string{"slack.token", "destination.address", "channels", "postmates.customer_id", "google_maps.api_key"} {
+		assert.Contains(t, err.Error(), key)
+	}
+}
+
+func TestLoadConfigRejectsUnknownSettings(t *testing.T) {
+	path, cleanup := writeTestConfig(t, testConfig+"chanels: [oops]\n")
+	defer cleanup()
+
+	_, err := LoadConfig(path)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "chanels")
+}
+
+func TestLoadConfigRequiresExplicitFileToExist(t *testing.T) {
+	_, err := LoadConfig("/definitely/not/a/garcon.yml")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "/definitely/not/a/garcon.yml")
+}
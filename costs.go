@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// CostSplit is how the extras on an order are shared out between the people on it
+type CostSplit struct {
+	// TaxRate and TipRate are fractions of what the food costs
+	TaxRate float64
+	TipRate float64
+	// ProportionalFees splits the delivery fee by how much everyone ordered, rather
+	// than evenly
+	ProportionalFees bool
+}
+
+// Share is one person's part of what an order costs, in the smallest unit of currency
+type Share struct {
+	Person   string
+	Subtotal int
+	Tax      int
+	Fee      int
+	Tip      int
+}
+
+// Total is everything the share adds up to
+func (sh Share) Total() int {
+	return sh.Subtotal + sh.Tax + sh.Fee + sh.Tip
+}
+
+// splitAmount divides an amount into parts weighted by the given weights, or evenly if
+// they're all zero. Leftover cents go to the parts that were shortchanged the most by
+// rounding, so the parts always add up to the whole.
+func splitAmount(amount int, weights []int) []int {
+	parts := make([]int, len(weights))
+	if len(weights) == 0 {
+		return parts
+	}
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		weights = make([]int, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+
+	leftover := amount
+	remainders := make([]int, len(weights))
+	for i, w := range weights {
+		parts[i] = amount * w / total
+		remainders[i] = amount * w % total
+		leftover -= parts[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; i < leftover; i++ {
+		parts[order[i%len(order)]]++
+	}
+	return parts
+}
+
+// shares works out what everyone on the session's order owes, from the prices of
+// what they ordered, plus their part of the tax, tip, and the delivery fee if it's
+// been quoted
+func (g *Garcon) shares(s *Session) []Share {
+	people := s.Order.People()
+	subtotals := make([]int, len(people))
+	for i, person := range people {
+		subtotals[i] = s.Order.Subtotal(person)
+	}
+	total := s.Order.Total()
+
+	taxes := splitAmount(int(math.Round(float64(total)*g.Costs.TaxRate)), subtotals)
+	tips := splitAmount(int(math.Round(float64(total)*g.Costs.TipRate)), subtotals)
+	fees := make([]int, len(people))
+	if s.Quote != nil {
+		if g.Costs.ProportionalFees {
+			fees = splitAmount(s.Quote.Fee, subtotals)
+		} else {
+			fees = splitAmount(s.Quote.Fee, make([]int, len(people)))
+		}
+	}
+
+	shares := []Share{}
+	for i, person := range people {
+		shares = append(shares, Share{Person: person, Subtotal: subtotals[i], Tax: taxes[i], Fee: fees[i], Tip: tips[i]})
+	}
+	return shares
+}
+
+// orderTotal adds up everyone's shares of the session's order
+func (g *Garcon) orderTotal(s *Session) Share {
+	total := Share{Person: "Total"}
+	for _, sh := range g.shares(s) {
+		total.Subtotal += sh.Subtotal
+		total.Tax += sh.Tax
+		total.Fee += sh.Fee
+		total.Tip += sh.Tip
+	}
+	return total
+}
+
+// describeShare spells out a share like "6.00 + 0.50 tax + 2.00 delivery = 8.50 USD",
+// leaving out anything that doesn't apply
+func (g *Garcon) describeShare(sh Share) string {
+	amount := func(a int) string {
+		return strings.TrimSpace(describeMoney(a, ""))
+	}
+	parts := []string{amount(sh.Subtotal)}
+	if sh.Tax > 0 {
+		parts = append(parts, amount(sh.Tax)+" tax")
+	}
+	if sh.Fee > 0 {
+		parts = append(parts, amount(sh.Fee)+" delivery")
+	}
+	if sh.Tip > 0 {
+		parts = append(parts, amount(sh.Tip)+" tip")
+	}
+	return fmt.Sprintf("%v: %v = %v", strings.Title(sh.Person), strings.Join(parts, " + "), describeMoney(sh.Total(), g.Limits.Currency))
+}
+
+// describeShares lists what everyone owes for the session's order, or nothing if
+// nobody's ordered anything with a price
+func (g *Garcon) describeShares(s *Session) string {
+	if s.Order.Total() == 0 {
+		return ""
+	}
+
+	lines := []string{}
+	unpriced := false
+	for _, sh := range g.shares(s) {
+		lines = append(lines, g.describeShare(sh))
+		for _, item := range s.Order[sh.Person] {
+			unpriced = unpriced || item.Price == 0
+		}
+	}
+	lines = append(lines, g.describeShare(g.orderTotal(s)))
+
+	t := fmt.Sprintf("Here's what everyone owes:\n```\n%v\n```", strings.Join(lines, "\n"))
+	if s.Quote == nil {
+		t += "\nThat's before delivery, which I'll add once it's quoted."
+	}
+	if unpriced {
+		t += "\nItems without prices aren't counted."
+	}
+	return t
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAmount(t *testing.T) {
+	assert.Equal(t, []int{200, 200, 199}, splitAmount(599, []int{0, 0, 0}), "even splits should still add up")
+	assert.Equal(t, []int{150, 450}, splitAmount(600, []int{500, 1500}))
+	assert.Equal(t, []int{33, 67}, splitAmount(100, []int{1, 2}))
+	assert.Equal(t, []int{}, splitAmount(100, []int{}))
+}
+
+func TestGarconShowsWhatEveryoneOwes(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.Costs = CostSplit{TaxRate: 0.1, TipRate: 0.15}
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
+	s.Order = Order{
+		"brainfart": []LineItem{LineItem{Quantity: 2, Name: "taco", Price: 300}},
+		"gary":      []LineItem{LineItem{Quantity: 1, Name: "burrito", Price: 800}, LineItem{Quantity: 1, Name: "secret sauce"}},
+	}
+
+	m.Text = "<@G4RC0NB0T> what's our order look like?"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\nBrainfart: 2x taco\nGary: 1x burrito, 1x secret sauce\n```\n"+
+		"Here's what everyone owes:\n```\n"+
+		"Brainfart: 6.00 + 0.60 tax + 0.90 tip = 7.50 USD\n"+
+		"Gary: 8.00 + 0.80 tax + 1.20 tip = 10.00 USD\n"+
+		"Total: 14.00 + 1.40 tax + 2.10 tip = 17.50 USD\n```\n"+
+		"That's before delivery, which I'll add once it's quoted.\n"+
+		"Items without prices aren't counted.", messages[0].Text)
+}
+
+func TestGarconSplitsTheDeliveryFeeWhenTheOrderIsSent(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(599)
+	g.Costs = CostSplit{ProportionalFees: true}
+	s.Order = Order{
+		"brainfart": []LineItem{LineItem{Quantity: 1, Name: "peach melba", Price: 500}},
+		"gary":      []LineItem{LineItem{Quantity: 1, Name: "banana split", Price: 1500}},
+	}
+
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+	assert.Equal(t, "Here's what everyone owes:\n```\n"+
+		"Brainfart: 5.00 + 1.50 delivery = 6.50 USD\n"+
+		"Gary: 15.00 + 4.49 delivery = 19.49 USD\n"+
+		"Total: 20.00 + 5.99 delivery = 25.99 USD\n```", messages[1].Text)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	orderDeadlinePattern    = "(orders|ordering) (close|closes|ends|end|will close) (?P<when>(at|in) .+)$"
+	relativeDeadlinePattern = "^in (?P<amount>[0-9]+|an|a|one) ?(?P<unit>minutes?|mins?|hours?|hrs?)$"
+	absoluteDeadlinePattern = "^at (?P<hour>[0-9]{1,2})(:(?P<minute>[0-9]{2}))? ?(?P<meridiem>am|pm|a\\.m\\.?|p\\.m\\.?)?$"
+)
+
+// parseDeadline works out when "in 20 minutes" or "at 11:45" is, relative to now. Times
+// are taken to be whenever's closest to now, so "at 1" said before lunch is 1 PM, and
+// if that's already passed they're rejected rather than pushed off to hours from now.
+func parseDeadline(when string, now time.Time) (time.Time, error) {
+	when = strings.TrimSpace(strings.ToLower(strings.TrimRight(when, ".!")))
+
+	if match, err := findElementsInString(relativeDeadlinePattern, []string{"amount", "unit"}, when); err == nil {
+		amount, err := strconv.Atoi(match["amount"])
+		if err != nil {
+			amount = 1
+		}
+		unit := time.Minute
+		if strings.HasPrefix(match["unit"], "h") {
+			unit = time.Hour
+		}
+		return now.Add(time.Duration(amount) * unit), nil
+	}
+
+	if when == "at noon" {
+		when = "at 12pm"
+	}
+	match, err := findElementsInString(absoluteDeadlinePattern, []string{"hour", "minute", "meridiem"}, when)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("I don't know when \"%v\" is", when)
+	}
+	hour, _ := strconv.Atoi(match["hour"])
+	minute, _ := strconv.Atoi(match["minute"])
+	if hour > 23 || minute > 59 || (len(match["meridiem"]) > 0 && (hour < 1 || hour > 12)) {
+		return time.Time{}, fmt.Errorf("%v doesn't look like a time to me", strings.TrimPrefix(when, "at "))
+	}
+
+	candidates := []int{hour}
+	switch {
+	case strings.HasPrefix(match["meridiem"], "a"):
+		candidates = []int{hour % 12}
+	case strings.HasPrefix(match["meridiem"], "p"):
+		candidates = []int{hour%12 + 12}
+	case hour <= 12:
+		candidates = []int{hour % 12, hour%12 + 12}
+	}
+
+	var closest time.Time
+	for _, day := range []int{0, 1} {
+		for _, h := range candidates {
+			t := time.Date(now.Year(), now.Month(), now.Day()+day, h, minute, 0, 0, now.Location())
+			if closest.IsZero() || absDuration(t.Sub(now)) < absDuration(closest.Sub(now)) {
+				closest = t
+			}
+		}
+	}
+	if !closest.After(now) {
+		return time.Time{}, fmt.Errorf("%v has already passed", strings.TrimPrefix(when, "at "))
+	}
+	return closest, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// describeDuration rounds a duration to the minute and spells it out
+func describeDuration(d time.Duration) string {
+	minutes := int((d + 30*time.Second) / time.Minute)
+	if minutes < 1 {
+		return "less than a minute"
+	}
+
+	parts := []string{}
+	if hours := minutes / 60; hours > 0 {
+		parts = append(parts, pluralize(hours, "hour"))
+	}
+	if minutes%60 > 0 {
+		parts = append(parts, pluralize(minutes%60, "minute"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("%v %v", n, unit)
+	}
+	return fmt.Sprintf("%v %vs", n, unit)
+}
+
+// deadlineStatus describes how long people have left to order, if there's a deadline
+func (g *Garcon) deadlineStatus(s *Session) string {
+	if s.Deadline.IsZero() {
+		return ""
+	}
+	at := s.Deadline.Format("3:04 PM")
+	if now := g.now(); s.Deadline.YearDay() != now.YearDay() || s.Deadline.Year() != now.Year() {
+		at += " tomorrow"
+	}
+	return fmt.Sprintf("Orders close at %v, %v from now.", at, describeDuration(s.Deadline.Sub(g.now())))
+}
+
+func (g *Garcon) setOrderDeadline(s *Session, m Message) []OutgoingMessage {
+	match, err := findElementsInString(orderDeadlinePattern, []string{"when"}, m.Text)
+	if err != nil {
+		return g.genericHelpResponse(s, m)
+	}
+
+	deadline, err := parseDeadline(match["when"], g.now())
+	if err != nil {
+		t := fmt.Sprintf("I'm sorry, @%v, %v. Try something like \"orders close at 11:45\" or \"orders close in 20 minutes\".", g.Patrons[m.User].Name, err)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+	s.Deadline = deadline
+	s.RemindersSent = 0
+
+	t := fmt.Sprintf("Okay! %v I'll remind everyone before then.", g.deadlineStatus(s))
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+// Tick is called periodically so Garcon can act on the passage of time rather than on
+// something someone said. It returns whatever Garcon would like to say as a result.
+func (g *Garcon) Tick(now time.Time) (responses []OutgoingMessage) {
+	for _, s := range g.Sessions.All() {
+		before := len(responses)
+
+		switch {
+		case s.Stage == "ordering" && !s.Deadline.IsZero():
+			responses = append(responses, about(s, g.checkOrderDeadline(s, now))...)
+		case s.Stage == "dispatched" && !s.Delivery.Untracked && now.Sub(s.DeliveryCheckedAt) >= g.DeliveryPollInterval:
+			responses = append(responses, about(s, g.refreshDelivery(s, now))...)
+		default:
+			responses = append(responses, about(s, g.checkIdleTimeout(s, now))...)
+		}
+
+		if len(responses) > before {
+			g.Sessions.Persist(s)
+		}
+	}
+	return append(responses, g.settleUp(now)...)
+}
+
+func (g *Garcon) checkOrderDeadline(s *Session, now time.Time) []OutgoingMessage {
+	m := Message{Channel: s.Channel, ThreadTimestamp: s.ThreadTimestamp, User: s.InterlocutorID}
+
+	if !now.Before(s.Deadline) {
+		log.Printf("The ordering deadline for %v has passed, so I'm closing the order.", s.Key)
+		s.Deadline = time.Time{}
+		s.LastActivity = now
+		timesUp := OutgoingMessage{Channel: s.Channel, Text: "Time's up! Orders are closed."}
+		return append([]OutgoingMessage{timesUp}, g.validateOrder(s, m)...)
+	}
+
+	remaining := s.Deadline.Sub(now)
+	due := s.RemindersSent
+	for due < len(g.DeadlineReminders) && remaining <= g.DeadlineReminders[due] {
+		due++
+	}
+	if due == s.RemindersSent {
+		return nil
+	}
+	s.RemindersSent = due
+
+	t := fmt.Sprintf("Heads up! Orders close in %v. Tell me what you'd like with \"@garcon, I'll have...\"", describeDuration(remaining))
+	return []OutgoingMessage{OutgoingMessage{Channel: s.Channel, Text: t}}
+}
+
+func (g *Garcon) checkIdleTimeout(s *Session, now time.Time) []OutgoingMessage {
+	timeout, ok := g.IdleTimeouts[s.Stage]
+	if !ok || timeout <= 0 {
+		return nil
+	}
+	if s.LastActivity.IsZero() {
+		// we don't know how long it's been idle, say if it was restored from an old snapshot,
+		// so start counting from now
+		s.LastActivity = now
+		return nil
+	}
+	if now.Sub(s.LastActivity) < timeout {
+		return nil
+	}
+
+	log.Printf("Nobody has said anything to me in %v for %v, so I'm closing the order.", s.Key, describeDuration(now.Sub(s.LastActivity)))
+	t := "It's been quiet for a while, so I'm closing this order. Say \"oh, garçon?\" if you'd like to start a new one."
+	if s.Stage == "dispatched" {
+		t = "I haven't heard anything about this delivery in a while, so I'm going to stop keeping track of it."
+		if s.Delivery.Untracked {
+			t = fmt.Sprintf("Nobody's told me the order from %v was picked up, so I'm going to stop keeping track of it.", s.ActualRestaurant.Name)
+		}
+		s.Record(now, "nobody heard anything about the delivery for %v", describeDuration(now.Sub(s.LastActivity)))
+		g.orderFinished(s, s.Delivery, now)
+	}
+	s.Reset()
+	return []OutgoingMessage{OutgoingMessage{Channel: s.Channel, Text: t}}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeadline(t *testing.T) {
+	now := time.Date(2016, 5, 6, 11, 20, 0, 0, time.UTC)
+	expectations := map[string]time.Time{
+		"in 20 minutes": time.Date(2016, 5, 6, 11, 40, 0, 0, time.UTC),
+		"in an hour":    time.Date(2016, 5, 6, 12, 20, 0, 0, time.UTC),
+		"at 11:45":      time.Date(2016, 5, 6, 11, 45, 0, 0, time.UTC),
+		"at 1":          time.Date(2016, 5, 6, 13, 0, 0, 0, time.UTC),
+		"at 1:15pm":     time.Date(2016, 5, 6, 13, 15, 0, 0, time.UTC),
+		"at noon":       time.Date(2016, 5, 6, 12, 0, 0, 0, time.UTC),
+		"at 11:30 a.m.": time.Date(2016, 5, 6, 11, 30, 0, 0, time.UTC),
+	}
+
+	for when, expected := range expectations {
+		actual, err := parseDeadline(when, now)
+		assert.Nil(t, err, when)
+		assert.Equal(t, expected, actual, when)
+	}
+
+	for _, when := range []string{"at lunch", "at 25:00", "in a jiffy", "at 11:15", "at 9am", "at 11:00 a.m."} {
+		_, err := parseDeadline(when, now)
+		assert.NotNil(t, err, when)
+	}
+
+	late := time.Date(2016, 5, 6, 22, 0, 0, 0, time.UTC)
+	actual, err := parseDeadline("at 1", late)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2016, 5, 7, 1, 0, 0, 0, time.UTC), actual, "late at night, 1 is in the morning")
+}
+
+func TestGarconWontSetADeadlineThatsPassed(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	now := time.Date(2016, 5, 6, 11, 50, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	s.Stage = "ordering"
+
+	m.Text = "orders close at 11:45"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, 11:45 has already passed. Try something like \"orders close at 11:45\" or \"orders close in 20 minutes\".", messages[0].Text)
+	assert.True(t, s.Deadline.IsZero())
+
+	now = time.Date(2016, 5, 6, 23, 30, 0, 0, time.UTC)
+	m.Text = "orders close at 12:15"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay! Orders close at 12:15 AM tomorrow, 45 minutes from now. I'll remind everyone before then.", messages[0].Text)
+}
+
+func TestGarconClosesOrdersAtTheDeadline(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	now := time.Date(2016, 5, 6, 11, 20, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba"})
+
+	m.Text = "orders close at 11:45"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay! Orders close at 11:45 AM, 25 minutes from now. I'll remind everyone before then.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T>, what's our order look like?"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\nBrainfart: 1x peach melba\n```\nOrders close at 11:45 AM, 25 minutes from now.", messages[0].Text)
+
+	assert.Equal(t, 0, len(g.Tick(now.Add(5*time.Minute))))
+
+	messages = g.Tick(now.Add(16 * time.Minute))
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "Heads up! Orders close in 9 minutes. Tell me what you'd like with \"@garcon, I'll have...\"", messages[0].Text)
+	assert.Equal(t, 0, len(g.Tick(now.Add(17*time.Minute))))
+
+	messages = g.Tick(now.Add(24 * time.Minute))
+	assert.Equal(t, "Heads up! Orders close in 1 minute. Tell me what you'd like with \"@garcon, I'll have...\"", messages[0].Text)
+
+	messages = g.Tick(now.Add(25 * time.Minute))
+	assert.Equal(t, 4, len(messages))
+	assert.Equal(t, "Time's up! Orders are closed.", messages[0].Text)
+	assert.Equal(t, "Is that correct?", messages[3].Text)
+	assert.Equal(t, "confirmation", s.Stage)
+}
+
+func TestOnlyTheInterlocutorCanSetADeadline(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+	s.Stage = "ordering"
+
+	m.User = "SOMEJERK"
+	m.Text = "orders close in 5 minutes"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @whocares, only @brainfart can do that for this order.", messages[0].Text)
+	assert.True(t, s.Deadline.IsZero())
+}
+
+func TestGarconClosesIdleSessions(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	now := time.Date(2016, 5, 6, 11, 20, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+
+	m.Text = "oh, garçon?"
+	g.RespondToMessage(m)
+	assert.Equal(t, "prompted", s.Stage)
+	assert.Equal(t, now, s.LastActivity)
+
+	assert.Equal(t, 0, len(g.Tick(now.Add(14*time.Minute))))
+
+	messages := g.Tick(now.Add(15 * time.Minute))
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "It's been quiet for a while, so I'm closing this order. Say \"oh, garçon?\" if you'd like to start a new one.", messages[0].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+
+	assert.Equal(t, 0, len(g.Tick(now.Add(time.Hour))))
+}
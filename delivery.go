@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeliverySpot is somewhere a delivery is picked up from or dropped off at
+type DeliverySpot struct {
+	Name        string
+	Address     string
+	PhoneNumber string
+}
+
+// NewDeliverySpot constructs a DeliverySpot
+func NewDeliverySpot(name, address, phoneNumber string) *DeliverySpot {
+	return &DeliverySpot{Name: name, Address: address, PhoneNumber: phoneNumber}
+}
+
+// Manifest describes what's being delivered
+type Manifest struct {
+	Description string
+	Reference   string
+}
+
+// DeliveryQuote is what a provider says a delivery would cost and how long it would take
+type DeliveryQuote struct {
+	ID         string
+	Fee        int // in the smallest unit of Currency, e.g. cents
+	Currency   string
+	Created    time.Time
+	Expires    time.Time
+	DropoffETA time.Time
+}
+
+// Delivery is a delivery a provider has agreed to make
+type Delivery struct {
+	ID          string
+	Status      string
+	Fee         int // in the smallest unit of Currency, e.g. cents
+	Currency    string
+	QuoteID     string
+	DropoffETA  time.Time
+	TrackingURL string
+	// Untracked deliveries are made by someone on the team rather than a courier, so
+	// there's nobody to ask how they're going, and they're over once someone says so
+	Untracked bool `json:",omitempty"`
+}
+
+// describeMoney spells out an amount given in the smallest unit of its currency
+func describeMoney(amount int, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%v%d.%02d %v", sign, amount/100, amount%100, strings.ToUpper(currency))
+}
+
+// parseMoney turns an amount like "8.50" into the smallest unit of its currency
+func parseMoney(amount string) (int, error) {
+	f, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(amount), "$"), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("I can't make sense of %v as an amount of money", amount)
+	}
+	return int(math.Round(f * 100)), nil
+}
+
+// DeliveryProvider is anyone who can get an order from a restaurant to us
+type DeliveryProvider interface {
+	// Quote asks how much it would cost to deliver from one spot to another
+	Quote(from, to *DeliverySpot) (*DeliveryQuote, error)
+	// CreateDelivery dispatches a delivery, ideally at the price of the given quote
+	CreateDelivery(manifest *Manifest, from, to *DeliverySpot, quote *DeliveryQuote) (*Delivery, error)
+	// DeliveryStatus returns the current state of a dispatched delivery
+	DeliveryStatus(id string) (*Delivery, error)
+	// CancelDelivery calls off a dispatched delivery
+	CancelDelivery(id string) (*Delivery, error)
+}
+
+// NewDeliveryProvider constructs the named delivery provider. Credentials are only
+// required by providers that talk to a third party.
+func NewDeliveryProvider(name, customerID, apiKey string) (DeliveryProvider, error) {
+	switch name {
+	case "", "postmates":
+		return NewPostmatesProvider(customerID, apiKey), nil
+	case "pickup":
+		return NewPickupProvider(), nil
+	}
+	return nil, fmt.Errorf("I don't know of a delivery provider called %v", name)
+}
+
+// isTemporary returns whether an error from a provider is the sort of thing that
+// might go away if we just try again
+func isTemporary(err error) bool {
+	if te, ok := err.(interface {
+		Temporary() bool
+	}); ok && te.Temporary() {
+		return true
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	return false
+}
+
+// withRetries calls f until it succeeds, fails with an error that isn't temporary, or
+// has been tried the given number of times, doubling the wait between each attempt. It
+// won't wait to try again past the timeout, since nobody else gets an answer until it's
+// done.
+func withRetries(attempts int, backoff, timeout time.Duration, f func() error) (err error) {
+	deadline := time.Now().Add(timeout)
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if time.Now().Add(backoff).After(deadline) {
+				log.Printf("That didn't work, and I've run out of time to try again:\n\t%v\n", err)
+				return
+			}
+			log.Printf("That didn't work, so I'm going to try again in %v:\n\t%v\n", backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err = f(); err == nil || !isTemporary(err) {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"googlemaps.github.io/maps"
+)
+
+// fakeMapsClient pretends that whatever restaurant you ask for exists, is open, and
+// is just around the corner
+type fakeMapsClient struct{}
+
+func (f fakeMapsClient) Geocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error) {
+	return []maps.GeocodingResult{
+		maps.GeocodingResult{
+			FormattedAddress: r.Address,
+			Geometry:         maps.AddressGeometry{Location: maps.LatLng{Lat: 30.3160, Lng: -97.7420}},
+		},
+	}, nil
+}
+
+func (f fakeMapsClient) NearbySearch(ctx context.Context, r *maps.NearbySearchRequest) (maps.PlacesSearchResponse, error) {
+	return maps.PlacesSearchResponse{
+		Results: []maps.PlacesSearchResult{
+			maps.PlacesSearchResult{
+				Name:     r.Name,
+				PlaceID:  fmt.Sprintf("fake:%v", r.Name),
+				Vicinity: "123 Fake St",
+				Geometry: maps.AddressGeometry{Location: *r.Location},
+			},
+		},
+	}, nil
+}
+
+func (f fakeMapsClient) PlaceDetails(ctx context.Context, r *maps.PlaceDetailsRequest) (maps.PlaceDetailsResult, error) {
+	return maps.PlaceDetailsResult{
+		Name:                 strings.TrimPrefix(r.PlaceID, "fake:"),
+		PlaceID:              r.PlaceID,
+		Vicinity:             "123 Fake St",
+		FormattedPhoneNumber: "(555) 555-5555",
+	}, nil
+}
+
+// fakeDeliveryStatuses are the statuses fake deliveries go through, a minute apiece
+var fakeDeliveryStatuses = []string{"pending", "pickup", "pickup_complete", "dropoff", "delivered"}
+
+// fakeDeliveryProvider quotes and "delivers" anything without ever talking to a courier.
+// Its deliveries move along a status every minute, so there's something to track.
+type fakeDeliveryProvider struct {
+	*PickupProvider
+	// dispatched maps the IDs of deliveries to when they were created
+	dispatched *sync.Map
+}
+
+func newFakeDeliveryProvider() fakeDeliveryProvider {
+	return fakeDeliveryProvider{NewPickupProvider(), &sync.Map{}}
+}
+
+func (f fakeDeliveryProvider) Quote(from, to *DeliverySpot) (*DeliveryQuote, error) {
+	now := time.Now()
+	return &DeliveryQuote{
+		ID:         fmt.Sprintf("fake-quote-%v", now.UnixNano()),
+		Fee:        599,
+		Currency:   "usd",
+		Created:    now,
+		Expires:    now.Add(5 * time.Minute),
+		DropoffETA: now.Add(time.Duration(len(fakeDeliveryStatuses)-1) * time.Minute),
+	}, nil
+}
+
+func (f fakeDeliveryProvider) CreateDelivery(manifest *Manifest, from, to *DeliverySpot, quote *DeliveryQuote) (*Delivery, error) {
+	log.Printf("I'm pretending to have sent a courier from %v to %v", from.Address, to.Address)
+	d, err := f.PickupProvider.CreateDelivery(manifest, from, to, quote)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	f.dispatched.Store(d.ID, now)
+	d.Status = fakeDeliveryStatuses[0]
+	d.Untracked = false
+	d.DropoffETA = now.Add(time.Duration(len(fakeDeliveryStatuses)-1) * time.Minute)
+	return d, nil
+}
+
+func (f fakeDeliveryProvider) DeliveryStatus(id string) (*Delivery, error) {
+	d, err := f.PickupProvider.DeliveryStatus(id)
+	if err != nil || d.Status == "canceled" {
+		return d, err
+	}
+	created, _ := f.dispatched.Load(id)
+	step := int(time.Since(created.(time.Time)) / time.Minute)
+	if step >= len(fakeDeliveryStatuses) {
+		step = len(fakeDeliveryStatuses) - 1
+	}
+	d.Status = fakeDeliveryStatuses[step]
+	d.DropoffETA = created.(time.Time).Add(time.Duration(len(fakeDeliveryStatuses)-1) * time.Minute)
+	return d, nil
+}
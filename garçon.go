@@ -4,11 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
-
-	"github.com/jasonmoo/ghostmates"
-	"github.com/nlopes/slack"
-	"golang.org/x/net/context"
-	"googlemaps.github.io/maps"
+	"time"
 )
 
 const (
@@ -19,28 +15,75 @@ const (
 	orderPlacingPattern             = "((I would|I'd) like|I'll have)(\\s*?)(?P<item>.*)"
 	orderStatusRequestPattern       = "(what does|what's) our order look like( so far)??"
 	orderConfirmationRequestPattern = "I think (we are|we're) ready( now)?"
+	scratchOrderPattern             = "^" + atGarconPattern + "(please )?(scratch|cancel|clear|forget) my order"
+	removeItemPattern               = "^" + atGarconPattern + "(please )?(remove|drop|take off) (my |the )?(?P<item>.+?)( from my order)?$"
+	changeItemPattern               = "^" + atGarconPattern + "(please )?change (my |the )?(?P<from>.+?) to (?P<to>.+)$"
+	retryOrderPattern               = "(try (that |it )?again|retry)"
+	abandonOrderPattern             = "(abandon|give up on|forget)( the| our)? order"
+)
+
+// the values of the buttons Garcon offers, which come back as a Message's Action
+const (
+	confirmAction     = "confirm"
+	startOverAction   = "start_over"
+	cancelAction      = "cancel"
+	noneOfTheseAction = "none"
 )
 
 // Garcon is our order taking bot! ヽ(゜∇゜)ノ
 type Garcon struct {
-	debug               bool
-	SelfName            string
-	SelfID              string
-	Stage               string
-	AllowedChannels     []string
-	InterlocutorID      string
-	RequestedRestaurant string
-	ActualRestaurant    *maps.PlaceDetailsResult
-	Order               map[string]string
-	Patrons             map[string]slack.User
-	MessageTypeFuncs    map[string]func(slack.Msg) (string, error)
-	ReactionFuncs       map[string]map[string]func(slack.Msg) []slack.OutgoingMessage
-	CommandExamples     map[string][]string
-
-	PostmatesClient  *ghostmates.Client
-	OrderDestination *ghostmates.DeliverySpot
-
-	GoogleMapsClient *maps.Client
+	debug            bool
+	SelfName         string
+	SelfID           string
+	AllowedChannels  []string
+	Sessions         *SessionManager
+	Patrons          map[string]Patron
+	MessageTypeFuncs map[string]func(*Session, Message) (string, error)
+	ReactionFuncs    map[string]map[string]func(*Session, Message) []OutgoingMessage
+	CommandExamples  map[string][]string
+	// RolesRequired are the roles needed for each type of message in each stage, for
+	// the message types not everyone is allowed to send
+	RolesRequired map[string]map[string]Role
+	// Roles are assigned by user ID, user name, or user group, all in lower case
+	Roles map[string]Role
+	// DefaultRole is the role of anyone who hasn't been assigned one
+	DefaultRole Role
+	// Interactive is whether clicks on buttons and menus make it back to Garcon. Without
+	// it, Garcon only offers choices as text.
+	Interactive bool
+
+	// DeadlineReminders are how long before an ordering deadline to remind people, longest first
+	DeadlineReminders []time.Duration
+	// IdleTimeouts are how long a session can sit in each stage with nobody talking to
+	// Garcon before it's abandoned. Stages without a timeout wait forever.
+	IdleTimeouts map[string]time.Duration
+	now          func() time.Time
+
+	DeliveryProvider DeliveryProvider
+	DeliveryAttempts int
+	DeliveryBackoff  time.Duration
+	// DeliveryTimeout is as long as Garcon will keep retrying a delivery request, since it
+	// can't answer anyone else in the meantime
+	DeliveryTimeout  time.Duration
+	OrderDestination *DeliverySpot
+	// DeliveryPollInterval is how often to ask the delivery provider how a dispatched
+	// delivery is going
+	DeliveryPollInterval time.Duration
+
+	RestaurantFinder RestaurantFinder
+	// Menus are where restaurants' menus come from, if anywhere. Without them, people
+	// can order anything at all.
+	Menus *MenuLibrary
+
+	// Limits are caps on what orders can cost, and Spending is what's been spent so far
+	Limits   SpendingLimits
+	Spending *Spending
+	// Costs are how tax, tip and delivery are shared out between everyone on an order
+	Costs CostSplit
+	// Ledger is who owes whom for the orders they've been on
+	Ledger *Ledger
+	// OrderHistory is every order that's been sent off
+	OrderHistory *OrderHistory
 }
 
 // FindBotSlackID iterates over all the slack users and figures out what
@@ -55,7 +98,7 @@ func (g *Garcon) FindBotSlackID() {
 
 // MessageAddressesGarcon returns whether or not the message began with some variant
 // of "ok, @garcon"
-func (g *Garcon) MessageAddressesGarcon(m slack.Msg) bool {
+func (g *Garcon) MessageAddressesGarcon(m Message) bool {
 	match, _ := findElementsInString(atGarconPattern, []string{"user"}, m.Text)
 	user := match["user"]
 	if _, ok := g.Patrons[user]; ok {
@@ -66,27 +109,35 @@ func (g *Garcon) MessageAddressesGarcon(m slack.Msg) bool {
 	return false
 }
 
-// Reset wipes the state of Garcon
-func (g *Garcon) Reset() {
-	g.Stage = "uninitiated"
-	g.InterlocutorID = ""
-	g.RequestedRestaurant = ""
-	g.Order = make(map[string]string)
-	g.ActualRestaurant = &maps.PlaceDetailsResult{}
-}
-
-// RespondToMessage TODO: Document
-func (g *Garcon) RespondToMessage(m slack.Msg) (responses []slack.OutgoingMessage) {
+// RespondToMessage finds the session the message belongs to and runs it through
+// that session's state machine
+func (g *Garcon) RespondToMessage(m Message) (responses []OutgoingMessage) {
 	if m.User == g.SelfID || len(m.User) == 0 {
 		log.Printf("I've received a message, but I can't respond to it because something's up with the user who sent it: '%v'", m.User)
 		return
 	}
 
+	if messageIsCommand(m) {
+		return g.respondToCommand(m)
+	}
+
 	if g.debug {
 		log.Printf("I've received this message:\n\t'%v'\nand I'm going to try to determine what type it is\n", m.Text)
 	}
 
-	mt, err := g.MessageTypeFuncs[g.Stage](m)
+	s := g.Sessions.SessionFor(m.Channel, m.ThreadTimestamp)
+	if g.ledgerRequested(m) {
+		return about(s, g.ledgerResponse(s, m))
+	}
+	if messageIsReaction(m) && s.Stage != "choosing" {
+		// reactions only mean something to us while we're waiting on a restaurant choice
+		return
+	}
+	if messageIsAction(m) && s.Stage != "choosing" && s.Stage != "confirmation" && s.Stage != "approval" {
+		// someone clicked on a button from a stage we've since moved on from
+		return
+	}
+	mt, err := g.MessageTypeFuncs[s.Stage](s, m)
 	if err != nil && g.debug {
 		log.Printf("I encountered this error determining the message type of that message:\n\t%v\n", err)
 	}
@@ -95,8 +146,15 @@ func (g *Garcon) RespondToMessage(m slack.Msg) (responses []slack.OutgoingMessag
 		log.Printf("I've determined this message:\n\t%v\nto be %v", m.Text, mt)
 	}
 
-	if _, ok := g.ReactionFuncs[g.Stage][mt]; ok {
-		responses = g.ReactionFuncs[g.Stage][mt](m)
+	if _, ok := g.ReactionFuncs[s.Stage][mt]; ok {
+		if !g.permitted(s, m, mt) {
+			return about(s, g.roleRequiredResponse(s, m, mt))
+		}
+		responses = about(s, g.ReactionFuncs[s.Stage][mt](s, m))
+		if s.Stage != "uninitiated" {
+			s.LastActivity = g.now()
+		}
+		g.Sessions.Persist(s)
 		if g.debug {
 			responseMessages := []string{}
 			for _, r := range responses {
@@ -110,8 +168,33 @@ func (g *Garcon) RespondToMessage(m slack.Msg) (responses []slack.OutgoingMessag
 	return
 }
 
+// about marks responses as being about the given session, so they land in its thread
+func about(s *Session, responses []OutgoingMessage) []OutgoingMessage {
+	for i := range responses {
+		responses[i].session = s
+	}
+	return responses
+}
+
+// Threaded addresses a response to the thread of the order it's about, if that order
+// has one. It's done as late as possible, since the thread may have only just started.
+func (g *Garcon) Threaded(r OutgoingMessage) OutgoingMessage {
+	if r.session != nil && !r.InChannel && len(r.ThreadTimestamp) == 0 {
+		r.ThreadTimestamp = r.session.ThreadTimestamp
+	}
+	return r
+}
+
+// ResponseSent lets Garcon know a response was sent, and what its timestamp was, so
+// an order can move into the thread under its first message
+func (g *Garcon) ResponseSent(r OutgoingMessage, timestamp string) {
+	if r.StartsThread && r.session != nil && len(r.session.ThreadTimestamp) == 0 && len(timestamp) > 0 {
+		g.Sessions.MoveToThread(r.session, timestamp)
+	}
+}
+
 // ItemAddedToOrder TODO: Document
-func (g Garcon) itemAddedToOrder(m slack.Msg) (orderPlaced bool) {
+func (g Garcon) itemAddedToOrder(m Message) (orderPlaced bool) {
 	messageAddressesGarcon := g.MessageAddressesGarcon(m)
 	messageIsPlacingAnOrder := stringFitsPattern(orderPlacingPattern, m.Text)
 	if messageAddressesGarcon && messageIsPlacingAnOrder {
@@ -136,107 +219,104 @@ func (g Garcon) itemAddedToOrder(m slack.Msg) (orderPlaced bool) {
 // TODO: Make these more generic
 // CancellationCommandIssued returns whether or not the most recent command
 // is a show-stopping cancellation command directed at Garcon
-func (g Garcon) cancellationCommandIssued(m slack.Msg) bool {
+func (g Garcon) cancellationCommandIssued(m Message) bool {
 	return g.MessageAddressesGarcon(m) && stringFitsPattern(abortCommandPattern, m.Text)
 }
 
 // OrderStatusCheckRequested TODO: Document
-func (g Garcon) orderStatusCheckRequested(m slack.Msg) bool {
+func (g Garcon) orderStatusCheckRequested(m Message) bool {
 	return stringFitsPattern(orderStatusRequestPattern, m.Text)
 }
 
 // ReadyToPlaceOrder TODO: Document
-func (g Garcon) readyToPlaceOrder(m slack.Msg) bool {
+func (g Garcon) readyToPlaceOrder(m Message) bool {
 	return stringFitsPattern(orderConfirmationRequestPattern, m.Text)
 }
 
 // HelpRequested TODO: Document
-func (g Garcon) helpRequested(m slack.Msg) bool {
+func (g Garcon) helpRequested(m Message) bool {
 	return g.MessageAddressesGarcon(m) && stringFitsPattern(helpRequestPattern, m.Text)
 }
 
-func (g *Garcon) suggestHelpCommandResponse(m slack.Msg) []slack.OutgoingMessage {
+func (g *Garcon) suggestHelpCommandResponse(s *Session, m Message) []OutgoingMessage {
 	t := fmt.Sprintf("I'm sorry, @%v, I couldn't understand what you said. For help, say \"@garcon, help me!\"", g.Patrons[m.User].Name)
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: t}}
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
 }
 
-func (g *Garcon) genericHelpResponse(m slack.Msg) []slack.OutgoingMessage {
-	s := "\n • "
-	examples := append(g.CommandExamples[g.Stage], g.CommandExamples["always"]...)
-	t := fmt.Sprintf("I'm sorry, @%v, I couldn't understand what you said. Here are some things I might understand:%v%v\n", g.Patrons[m.User].Name, s, strings.Join(examples, s))
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: t}}
+func (g *Garcon) genericHelpResponse(s *Session, m Message) []OutgoingMessage {
+	sep := "\n • "
+	examples := append(g.CommandExamples[s.Stage], g.CommandExamples["always"]...)
+	t := fmt.Sprintf("I'm sorry, @%v, I couldn't understand what you said. Here are some things I might understand:%v%v\n", g.Patrons[m.User].Name, sep, strings.Join(examples, sep))
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
 }
 
-func (g *Garcon) helloGarcon(m slack.Msg) []slack.OutgoingMessage {
+func (g *Garcon) helloGarcon(s *Session, m Message) []OutgoingMessage {
 	t := fmt.Sprintf("Hi, @%v! Would you like to place an order?", g.Patrons[m.User].Name)
-	g.InterlocutorID = m.User
-	g.Stage = "prompted"
-
-	return []slack.OutgoingMessage{
-		slack.OutgoingMessage{Channel: m.Channel, Text: t},
+	s.InterlocutorID = m.User
+	s.Stage = "prompted"
+	s.History = nil
+	s.Record(g.now(), "%v started an order", g.Patrons[m.User].Name)
+
+	hello := OutgoingMessage{Channel: m.Channel, Text: t, StartsThread: true}
+	if len(m.ThreadTimestamp) > 0 {
+		// we were asked from inside a thread, so we'll carry on in that one
+		g.Sessions.MoveToThread(s, m.ThreadTimestamp)
+		hello.StartsThread = false
 	}
+	return []OutgoingMessage{hello}
 }
 
-func (g *Garcon) validateRestaurant(m slack.Msg) []slack.OutgoingMessage {
+func (g *Garcon) validateRestaurant(s *Session, m Message) []OutgoingMessage {
 	match, err := findElementsInString(orderInitiationPattern, []string{"restaurant"}, m.Text)
-	g.RequestedRestaurant = match["restaurant"]
-
-	if err != nil || len(g.RequestedRestaurant) == 0 {
-		return g.suggestHelpCommandResponse(m)
-	}
-
-	gr := &maps.GeocodingRequest{Address: g.OrderDestination.Address}
-	gresp, err := g.GoogleMapsClient.Geocode(context.TODO(), gr)
-	if err != nil {
-		log.Printf("fatal error geocoding Location: %s", err)
-		return g.suggestHelpCommandResponse(m)
-	}
+	s.RequestedRestaurant = match["restaurant"]
 
-	nsr := &maps.NearbySearchRequest{
-		Location: &gresp[0].Geometry.Location,
-		Radius:   10000, // In meters, this is completely arbitrary
-		Keyword:  g.RequestedRestaurant,
-		Name:     g.RequestedRestaurant,
-		OpenNow:  true,
-		MinPrice: "0",
-		MaxPrice: "4",
-		Type:     "restaurant",
+	if err != nil || len(s.RequestedRestaurant) == 0 {
+		return g.suggestHelpCommandResponse(s, m)
 	}
 
-	places, err := g.GoogleMapsClient.NearbySearch(context.TODO(), nsr)
-	log.Printf("I was able to find %v restaurants matching the requested name.", len(places.Results))
+	restaurants, err := g.RestaurantFinder.FindRestaurants(s.RequestedRestaurant, g.OrderDestination.Address)
+	log.Printf("I was able to find %v restaurants matching the requested name.", len(restaurants))
 	if err != nil {
-		log.Printf("fatal error conducting search: %s", err)
+		log.Printf("error finding restaurants: %s", err)
 		em := "I'm sorry, I can't find a restaurant like that."
-		return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: em}}
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: em}}
 	}
-	pdr := &maps.PlaceDetailsRequest{PlaceID: places.Results[0].PlaceID}
-	place, err := g.GoogleMapsClient.PlaceDetails(context.TODO(), pdr)
-
-	g.ActualRestaurant = &place
-	log.Printf("I decided to go with the following restaurant:\n%v\n%v\n", g.ActualRestaurant.Name, g.ActualRestaurant.Vicinity)
 
-	t := fmt.Sprintf("Okay, what would everyone like from %v?", g.ActualRestaurant.Name)
-	g.Stage = "ordering"
-
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: t}}
+	switch len(restaurants) {
+	case 0:
+		t := fmt.Sprintf("I'm sorry, I couldn't find any open restaurants nearby matching \"%v\". Is there somewhere else you'd like to order from?", s.RequestedRestaurant)
+		s.RequestedRestaurant = ""
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	case 1:
+		return g.settleOnRestaurant(s, m, restaurants[0])
+	}
+	return g.chooseAmongRestaurants(s, m, restaurants)
 }
 
-func (g *Garcon) validateOrder(m slack.Msg) []slack.OutgoingMessage {
-	g.Stage = "confirmation"
+func (g *Garcon) validateOrder(s *Session, m Message) []OutgoingMessage {
+	s.Stage = "confirmation"
 
 	if g.debug {
 		log.Printf("I'm going to attempt to confirm the group order.")
 	}
 
-	return []slack.OutgoingMessage{
-		slack.OutgoingMessage{Channel: m.Channel, Text: "Alright, then!"},
-		g.orderStatusResponse(m)[0],
-		slack.OutgoingMessage{Channel: m.Channel, Text: "Is that correct?"},
+	question := OutgoingMessage{Channel: m.Channel, Text: "Is that correct?"}
+	if g.Interactive {
+		question.Buttons = []Choice{
+			Choice{Text: "Confirm", Value: confirmAction, Style: "primary"},
+			Choice{Text: "Start over", Value: startOverAction},
+			Choice{Text: "Cancel", Value: cancelAction, Style: "danger"},
+		}
+	}
+
+	return []OutgoingMessage{
+		OutgoingMessage{Channel: m.Channel, Text: "Alright, then!"},
+		g.orderStatusResponse(s, m)[0],
+		question,
 	}
 }
 
-func (g *Garcon) addItemToGroupOrder(m slack.Msg) []slack.OutgoingMessage {
+func (g *Garcon) addItemToGroupOrder(s *Session, m Message) []OutgoingMessage {
 	matches, err := findElementsInString(orderPlacingPattern, []string{"item"}, m.Text)
 	item := strings.TrimSpace(matches["item"])
 
@@ -245,73 +325,241 @@ func (g *Garcon) addItemToGroupOrder(m slack.Msg) []slack.OutgoingMessage {
 	}
 
 	if err != nil || len(item) == 0 {
-		return g.genericHelpResponse(m)
+		return g.genericHelpResponse(s, m)
+	}
+
+	li, err := parseLineItem(item)
+	if err != nil {
+		t := fmt.Sprintf("I'm sorry, @%v, %v", g.Patrons[m.User].Name, err)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
 	}
+	if s.Menu != nil {
+		var responses []OutgoingMessage
+		var ok bool
+		if li, responses, ok = g.checkAgainstMenu(s, m, li, ""); !ok {
+			return responses
+		}
+	}
+	return g.addLineItem(s, m, li)
+}
 
-	g.Order[g.Patrons[m.User].Name] = item
+// addLineItem puts an item on the order for whoever sent the message
+func (g *Garcon) addLineItem(s *Session, m Message, li LineItem) []OutgoingMessage {
+	s.Order.Add(g.Patrons[m.User].Name, li)
 
-	t := fmt.Sprintf("Okay @%v, I've got your order.", g.Patrons[m.User].Name)
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: t}}
+	t := fmt.Sprintf("Okay @%v, I've added %v to your order.", g.Patrons[m.User].Name, li)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
 }
 
-func (g *Garcon) orderIsIncorrect(m slack.Msg) []slack.OutgoingMessage {
-	t := fmt.Sprintf("Okay, I'll start over.")
+func (g *Garcon) scratchOrder(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	delete(s.Order, name)
+	delete(s.Clarifications, name)
 
-	g.Stage = "ordering"
-	g.RequestedRestaurant = ""
-	g.Order = make(map[string]string)
+	t := fmt.Sprintf("Okay @%v, I've taken you off the order.", name)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
 
-	return []slack.OutgoingMessage{
-		slack.OutgoingMessage{Channel: m.Channel, Text: t},
+func (g *Garcon) removeItemFromGroupOrder(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	matches, err := findElementsInString(removeItemPattern, []string{"item"}, m.Text)
+	if err != nil {
+		return g.genericHelpResponse(s, m)
+	}
+	li, err := parseLineItem(matches["item"])
+	if err != nil {
+		return g.genericHelpResponse(s, m)
 	}
-}
 
-func (g *Garcon) placeOrder(m slack.Msg) []slack.OutgoingMessage {
-	restaurantName := g.ActualRestaurant.Name
-	restaurantAddress := g.ActualRestaurant.Vicinity
-	restaurantPhone := g.ActualRestaurant.FormattedPhoneNumber
+	removed, ok := s.Order.Remove(name, li.Name)
+	if !ok {
+		t := fmt.Sprintf("I'm sorry, @%v, I don't see %v in your order.", name, li.Name)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
 
-	t := "Okay, I'll send this order off!"
+	t := fmt.Sprintf("Okay @%v, I've removed %v from your order.", name, removed)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
 
-	manifest := *ghostmates.NewManifest(g.createOrderString(), "Group Order")
-	from := *ghostmates.NewDeliverySpot(restaurantName, restaurantAddress, restaurantPhone)
-	to := g.OrderDestination
-	quote, err := g.PostmatesClient.GetQuote(from.Address, to.Address)
+func (g *Garcon) changeItemInGroupOrder(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	matches, err := findElementsInString(changeItemPattern, []string{"from", "to"}, m.Text)
 	if err != nil {
-		t = "I'm having some problems placing this order, please check the logs."
-		log.Println("Error creating quote")
-		log.Fatal(err)
+		return g.genericHelpResponse(s, m)
 	}
-
-	err = g.PostmatesClient.CreateDelivery(&manifest, &from, to, quote)
+	from, err := parseLineItem(matches["from"])
 	if err != nil {
-		t = "I'm having some problems placing this order, please check the logs."
-		log.Println("Error creating delivery")
-		log.Fatal(err)
+		return g.genericHelpResponse(s, m)
+	}
+	to, err := parseLineItem(matches["to"])
+	if err != nil {
+		t := fmt.Sprintf("I'm sorry, @%v, %v", name, err)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+	if s.Order.find(name, from.Name) < 0 {
+		t := fmt.Sprintf("I'm sorry, @%v, I don't see %v in your order.", name, from.Name)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+	if s.Menu != nil {
+		var responses []OutgoingMessage
+		var ok bool
+		if to, responses, ok = g.checkAgainstMenu(s, m, to, from.Name); !ok {
+			return responses
+		}
 	}
-	g.Reset()
+	return g.replaceLineItem(s, m, from.Name, to)
+}
+
+// replaceLineItem swaps one of the items on the order for whoever sent the message
+// for another
+func (g *Garcon) replaceLineItem(s *Session, m Message, from string, to LineItem) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	replaced, ok := s.Order.Replace(name, from, to)
+	if !ok {
+		t := fmt.Sprintf("I'm sorry, @%v, I don't see %v in your order.", name, from)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+
+	t := fmt.Sprintf("Okay @%v, I've changed %v to %v.", name, replaced, to)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+func (g *Garcon) orderIsIncorrect(s *Session, m Message) []OutgoingMessage {
+	t := fmt.Sprintf("Okay, I'll start over.")
+
+	s.Stage = "ordering"
+	s.RequestedRestaurant = ""
+	s.Order = make(Order)
+	s.Clarifications = nil
+	s.Deadline = time.Time{}
+	s.LimitsOverridden = false
+
+	return []OutgoingMessage{
+		OutgoingMessage{Channel: m.Channel, Text: t},
+	}
+}
+
+// restaurantName is the name of the restaurant the session's order is from, or the one
+// that was asked for if Garcon hasn't settled on one yet
+func restaurantName(s *Session) string {
+	if len(s.ActualRestaurant.Name) > 0 {
+		return s.ActualRestaurant.Name
+	}
+	return s.RequestedRestaurant
+}
+
+// orderSummary describes a placed order in a line, for everyone in the channel who
+// wasn't following along in its thread
+func (g *Garcon) orderSummary(s *Session) string {
+	restaurant := restaurantName(s)
+	items := 0
+	people := []string{}
+	for _, person := range s.Order.People() {
+		people = append(people, strings.Title(person))
+		for _, item := range s.Order[person] {
+			items += item.Quantity
+		}
+	}
+	return fmt.Sprintf("@%v just sent off an order from %v: %v for %v.", g.Patrons[s.InterlocutorID].Name, restaurant, pluralize(items, "item"), strings.Join(people, ", "))
+}
 
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: t}}
+// deliveryFailedResponse leaves the session where it is so nothing is lost, and lets
+// everyone know what went wrong and what can be done about it
+func (g *Garcon) deliveryFailedResponse(s *Session, m Message, attempted string, err error) []OutgoingMessage {
+	log.Printf("I wasn't able to %v:\n\t%v\n", attempted, err)
+	s.DeliveryError = fmt.Sprintf("I wasn't able to %v: %v", attempted, err)
+
+	t := fmt.Sprintf("I'm having some problems placing this order. %v\n@%v, say \"@garcon, try again\" to have me retry, or \"@garcon, abandon the order\" to give up on it.", s.DeliveryError, g.Patrons[s.InterlocutorID].Name)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+func (g *Garcon) abandonOrder(s *Session, m Message) []OutgoingMessage {
+	s.Reset()
+	t := "Okay, I've abandoned this order. Nothing was sent."
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+func (g *Garcon) notAllowedResponse(s *Session, m Message) []OutgoingMessage {
+	t := fmt.Sprintf("I'm sorry, @%v, only @%v can do that for this order.", g.Patrons[m.User].Name, g.Patrons[s.InterlocutorID].Name)
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
 }
 
-func (g *Garcon) createOrderString() string {
+func (g *Garcon) createOrderString(s *Session) string {
 	orders := ""
-	for user, order := range g.Order {
-		orders = fmt.Sprintf("%v%v: %v\n", orders, strings.Title(user), order)
+	for _, user := range s.Order.People() {
+		orders = fmt.Sprintf("%v%v: %v\n", orders, strings.Title(user), describeItems(s.Order[user]))
 	}
 	return strings.TrimSpace(orders)
 }
 
-func (g *Garcon) orderStatusResponse(m slack.Msg) []slack.OutgoingMessage {
+func (g *Garcon) orderStatusResponse(s *Session, m Message) []OutgoingMessage {
 	statusTemplate := "Here's what I have for your order from %v:\n```\n%v\n```"
-	statusMessage := fmt.Sprintf(statusTemplate, g.RequestedRestaurant, g.createOrderString())
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: statusMessage}}
+	statusMessage := fmt.Sprintf(statusTemplate, restaurantName(s), g.createOrderString(s))
+	if shares := g.describeShares(s); len(shares) > 0 {
+		statusMessage = fmt.Sprintf("%v\n%v", statusMessage, shares)
+	}
+	if s.Stage == "ordering" && !s.Deadline.IsZero() {
+		statusMessage = fmt.Sprintf("%v\n%v", statusMessage, g.deadlineStatus(s))
+	}
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: statusMessage}}
 }
 
-func (g *Garcon) genericCancelReponse(m slack.Msg) []slack.OutgoingMessage {
-	g.Reset()
+func (g *Garcon) genericCancelReponse(s *Session, m Message) []OutgoingMessage {
+	s.Reset()
 	t := "Very well then, I'll disappear for now!"
-	return []slack.OutgoingMessage{slack.OutgoingMessage{Channel: m.Channel, Text: t}}
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+// confirmationMessageType works out what a message means when Garcon is waiting on a
+// yes or no. Whether it was typed or clicked, only the interlocutor (or an admin) gets to
+// say yes or no to their order.
+func (g *Garcon) confirmationMessageType(s *Session, m Message) (string, error) {
+	mt, err := g.confirmationAnswer(s, m)
+	if (mt == "affirmative" || mt == "negative") && !g.speaksFor(s, m) {
+		return "forbidden", err
+	}
+	return mt, err
+}
+
+// confirmationAnswer reads a click the same as its typed equivalent, so both go through
+// the same checks on who's allowed to do what
+func (g *Garcon) confirmationAnswer(s *Session, m Message) (string, error) {
+	if messageIsAction(m) {
+		switch m.Action {
+		case confirmAction:
+			return "affirmative", nil
+		case startOverAction, notYetAction:
+			return "negative", nil
+		case cancelAction:
+			return "cancelling", nil
+		}
+		return "irrelevant", nil
+	}
+	if g.cancellationCommandIssued(m) {
+		return "cancelling", nil
+	}
+	if g.helpRequested(m) {
+		return "insufficient", nil
+	}
+	if s.Stage == "confirmation" && g.MessageAddressesGarcon(m) && stringFitsPattern(overrideLimitsPattern, m.Text) {
+		return "overriding", nil
+	}
+	if len(s.DeliveryError) > 0 && g.MessageAddressesGarcon(m) && (stringFitsPattern(retryOrderPattern, m.Text) || stringFitsPattern(abandonOrderPattern, m.Text)) {
+		if !g.speaksFor(s, m) {
+			return "forbidden", nil
+		}
+		if stringFitsPattern(abandonOrderPattern, m.Text) {
+			return "abandoning", nil
+		}
+		return "retrying", nil
+	}
+	if responseIsAffirmative(m.Text) {
+		return "affirmative", nil
+	}
+	if responseIsNegative(m.Text) {
+		return "negative", nil
+	}
+	return "insufficient", nil
 }
 
 // NewGarcon constructs a new instance of Garcon and establishes all the behavior functions
@@ -320,9 +568,31 @@ func (g *Garcon) genericCancelReponse(m slack.Msg) []slack.OutgoingMessage {
 // the code either hilariously unreadable, complicated, and most likely both
 func NewGarcon() *Garcon {
 	g := &Garcon{
-		SelfName: "garcon",
-		Stage:    "uninitiated",
-		Order:    make(map[string]string),
+		SelfName:             "garcon",
+		Sessions:             NewSessionManager(),
+		DeliveryAttempts:     3,
+		DeliveryBackoff:      time.Second,
+		DeliveryTimeout:      10 * time.Second,
+		DeliveryPollInterval: time.Minute,
+		DeadlineReminders: []time.Duration{
+			10 * time.Minute,
+			2 * time.Minute,
+		},
+		IdleTimeouts: map[string]time.Duration{
+			"prompted":     15 * time.Minute,
+			"choosing":     15 * time.Minute,
+			"ordering":     2 * time.Hour,
+			"confirmation": 30 * time.Minute,
+			"approval":     30 * time.Minute,
+			"dispatched":   3 * time.Hour,
+		},
+		now:          time.Now,
+		Roles:        map[string]Role{},
+		DefaultRole:  OrdererRole,
+		Limits:       SpendingLimits{Currency: "usd"},
+		Spending:     &Spending{Months: make(map[string]int)},
+		Ledger:       &Ledger{},
+		OrderHistory: &OrderHistory{},
 	}
 
 	g.CommandExamples = map[string][]string{
@@ -330,24 +600,48 @@ func NewGarcon() *Garcon {
 			"We'd like to place an order for the Chili's at 45th & Lamar",
 			"We would like to order from the Chili's at 45th & Lamar",
 		},
+		"choosing": []string{
+			"2",
+			"the first one",
+			"none of those",
+		},
 		"ordering": []string{
 			"@garcon, I'd like a banana",
 			"@garcon I'll have the tuna melt",
 			"@garcon, what's our order look like so far?",
+			"@garcon, remove the fries",
+			"@garcon, change my banana to an apple",
+			"@garcon, I'll have my usual",
+			"@garcon, same as last Friday",
+			"@garcon, scratch my order",
+			"orders close at 11:45",
+			"orders close in 20 minutes",
 		},
 		"confirmation": []string{
 			"yes",
 			"no",
+			"@garcon, override the limits",
+		},
+		"approval": []string{
+			"yes",
+			"no",
+		},
+		"dispatched": []string{
+			"@garcon, where's our food?",
+			"@garcon, cancel the delivery",
+			"@garcon, we picked up the food",
 		},
 		"always": []string{
 			"@garcon, go away",
 			"@garcon, help!",
+			"@garcon, what do I owe?",
+			"@garcon, alice paid me back $12",
 		},
 	}
 
 	// possible returns: affirmative, negative, additive, cancelling, irrelevant, insufficient
-	g.MessageTypeFuncs = map[string]func(slack.Msg) (string, error){
-		"uninitiated": func(m slack.Msg) (string, error) {
+	g.MessageTypeFuncs = map[string]func(*Session, Message) (string, error){
+		"uninitiated": func(s *Session, m Message) (string, error) {
 			if g.cancellationCommandIssued(m) {
 				return "cancelling", nil
 			}
@@ -356,14 +650,14 @@ func NewGarcon() *Garcon {
 			}
 			return "irrelevant", nil
 		},
-		"prompted": func(m slack.Msg) (string, error) {
+		"prompted": func(s *Session, m Message) (string, error) {
 			if g.cancellationCommandIssued(m) {
 				return "cancelling", nil
 			}
 			if g.helpRequested(m) {
 				return "insufficient", nil
 			}
-			if responseIsNegative(m.Text) || m.User != g.InterlocutorID {
+			if responseIsNegative(m.Text) || m.User != s.InterlocutorID {
 				return "negative", nil
 			}
 			if stringFitsPattern(orderInitiationPattern, m.Text) {
@@ -371,13 +665,63 @@ func NewGarcon() *Garcon {
 			}
 			return "insufficient", nil
 		},
-		"ordering": func(m slack.Msg) (string, error) {
+		"choosing": func(s *Session, m Message) (string, error) {
+			if g.cancellationCommandIssued(m) {
+				return "cancelling", nil
+			}
+			if g.helpRequested(m) {
+				return "insufficient", nil
+			}
+			if !g.speaksFor(s, m) {
+				if messageIsAction(m) {
+					return "forbidden", nil
+				}
+				return "irrelevant", nil
+			}
+			if restaurantChoice(s, m) >= 0 {
+				return "affirmative", nil
+			}
+			if messageIsReaction(m) {
+				return "irrelevant", nil
+			}
+			if m.Action == noneOfTheseAction || noneOfTheseChoices(m) {
+				return "negative", nil
+			}
+			if messageIsAction(m) {
+				return "irrelevant", nil
+			}
+			return "insufficient", nil
+		},
+		"ordering": func(s *Session, m Message) (string, error) {
 			if g.cancellationCommandIssued(m) {
 				return "cancelling", nil
 			}
 			if g.helpRequested(m) {
 				return "insufficient", nil
 			}
+			if stringFitsPattern(orderDeadlinePattern, m.Text) {
+				if !g.speaksFor(s, m) {
+					return "forbidden", nil
+				}
+				return "scheduling", nil
+			}
+			if g.MessageAddressesGarcon(m) {
+				if stringFitsPattern(scratchOrderPattern, m.Text) {
+					return "scratching", nil
+				}
+				if stringFitsPattern(changeItemPattern, m.Text) {
+					return "changing", nil
+				}
+				if stringFitsPattern(removeItemPattern, m.Text) {
+					return "removing", nil
+				}
+			}
+			if g.awaitingClarification(s, m) {
+				return "clarifying", nil
+			}
+			if g.reorderRequested(m) {
+				return "reordering", nil
+			}
 			if g.itemAddedToOrder(m) {
 				return "contributing", nil
 			}
@@ -389,45 +733,117 @@ func NewGarcon() *Garcon {
 			}
 			return "indeterminable", nil
 		},
-		"confirmation": func(m slack.Msg) (string, error) {
-			if g.cancellationCommandIssued(m) {
-				return "cancelling", nil
-			}
+		"confirmation": g.confirmationMessageType,
+		"dispatched": func(s *Session, m Message) (string, error) {
 			if g.helpRequested(m) {
 				return "insufficient", nil
 			}
-			if responseIsAffirmative(m.Text) {
-				return "affirmative", nil
+			if stringFitsPattern(deliveryStatusRequestPattern, m.Text) {
+				return "tracking", nil
 			}
-			if responseIsNegative(m.Text) {
-				return "negative", nil
+			if stringFitsPattern(cancelDeliveryPattern, m.Text) {
+				if !g.speaksFor(s, m) {
+					return "forbidden", nil
+				}
+				return "cancelling", nil
 			}
-			return "insufficient", nil
+			if stringFitsPattern(collectedPattern, m.Text) {
+				return "collected", nil
+			}
+			return "irrelevant", nil
+		},
+		"approval": g.confirmationMessageType,
+	}
+
+	// anyone can add to an order, but only orderers can start one, send one off, or call one off
+	g.RolesRequired = map[string]map[string]Role{
+		"uninitiated": map[string]Role{
+			"affirmative": OrdererRole,
+		},
+		"prompted": map[string]Role{
+			"cancelling": OrdererRole,
+		},
+		"choosing": map[string]Role{
+			"cancelling": OrdererRole,
+		},
+		"ordering": map[string]Role{
+			"affirmative": OrdererRole,
+			"cancelling":  OrdererRole,
+		},
+		"confirmation": map[string]Role{
+			"affirmative": OrdererRole,
+			"retrying":    OrdererRole,
+			"abandoning":  OrdererRole,
+			"cancelling":  OrdererRole,
+			"overriding":  AdminRole,
+		},
+		"approval": map[string]Role{
+			"affirmative": OrdererRole,
+			"retrying":    OrdererRole,
+			"abandoning":  OrdererRole,
+			"cancelling":  OrdererRole,
+		},
+		"dispatched": map[string]Role{
+			"cancelling": OrdererRole,
 		},
 	}
 
-	g.ReactionFuncs = map[string]map[string]func(slack.Msg) []slack.OutgoingMessage{
-		"uninitiated": map[string]func(m slack.Msg) []slack.OutgoingMessage{
+	g.ReactionFuncs = map[string]map[string]func(*Session, Message) []OutgoingMessage{
+		"uninitiated": map[string]func(*Session, Message) []OutgoingMessage{
 			"affirmative": g.helloGarcon,
 		},
-		"prompted": map[string]func(m slack.Msg) []slack.OutgoingMessage{
+		"prompted": map[string]func(*Session, Message) []OutgoingMessage{
 			"affirmative":  g.validateRestaurant,
 			"insufficient": g.genericHelpResponse,
 			"cancelling":   g.genericCancelReponse,
 		},
-		"ordering": map[string]func(m slack.Msg) []slack.OutgoingMessage{
+		"choosing": map[string]func(*Session, Message) []OutgoingMessage{
+			"affirmative":  g.restaurantChosen,
+			"negative":     g.noRestaurantChosen,
+			"forbidden":    g.notAllowedResponse,
+			"insufficient": g.genericHelpResponse,
+			"cancelling":   g.genericCancelReponse,
+		},
+		"ordering": map[string]func(*Session, Message) []OutgoingMessage{
 			"affirmative":  g.validateOrder,
 			"contributing": g.addItemToGroupOrder,
+			"clarifying":   g.clarifyItem,
+			"reordering":   g.reorder,
+			"scratching":   g.scratchOrder,
+			"removing":     g.removeItemFromGroupOrder,
+			"changing":     g.changeItemInGroupOrder,
+			"scheduling":   g.setOrderDeadline,
+			"forbidden":    g.notAllowedResponse,
 			"insufficient": g.genericHelpResponse,
 			"cancelling":   g.genericCancelReponse,
 			"status":       g.orderStatusResponse,
 		},
-		"confirmation": map[string]func(m slack.Msg) []slack.OutgoingMessage{
-			"affirmative":  g.placeOrder,
+		"confirmation": map[string]func(*Session, Message) []OutgoingMessage{
+			"affirmative":  g.quoteOrder,
+			"retrying":     g.quoteOrder,
+			"overriding":   g.overrideLimits,
+			"abandoning":   g.abandonOrder,
+			"forbidden":    g.notAllowedResponse,
 			"negative":     g.orderIsIncorrect,
 			"cancelling":   g.genericCancelReponse,
 			"insufficient": g.genericHelpResponse,
 		},
+		"approval": map[string]func(*Session, Message) []OutgoingMessage{
+			"affirmative":  g.dispatchOrder,
+			"retrying":     g.dispatchOrder,
+			"abandoning":   g.abandonOrder,
+			"forbidden":    g.notAllowedResponse,
+			"negative":     g.declineQuote,
+			"cancelling":   g.genericCancelReponse,
+			"insufficient": g.genericHelpResponse,
+		},
+		"dispatched": map[string]func(*Session, Message) []OutgoingMessage{
+			"tracking":     g.deliveryStatusResponse,
+			"cancelling":   g.cancelDelivery,
+			"collected":    g.collected,
+			"forbidden":    g.notAllowedResponse,
+			"insufficient": g.genericHelpResponse,
+		},
 	}
 
 	return g
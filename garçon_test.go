@@ -3,52 +3,61 @@ package main
 import (
 	"fmt"
 	"testing"
+	"time"
 
-	"github.com/nlopes/slack"
 	"github.com/stretchr/testify/assert"
 )
 
-func returnGarconAndEmptyMessage() (*Garcon, slack.Msg) {
+func returnGarconAndEmptyMessage() (*Garcon, *Session, Message) {
 	dummyUserID := "L0LWTFBBQ"
 	g := NewGarcon()
 	g.SelfID = "G4RC0NB0T"
-	g.Patrons = map[string]slack.User{
-		"L0LWTFBBQ": slack.User{
+	g.RestaurantFinder = NewGoogleRestaurantFinder(fakeMapsClient{})
+	g.DeliveryProvider = newFakeDeliveryProvider()
+	g.OrderDestination = NewDeliverySpot("The Office", "1 Main St", "(555) 555-0000")
+	g.Patrons = map[string]Patron{
+		"L0LWTFBBQ": Patron{
 			ID:   dummyUserID,
 			Name: "brainfart",
 		},
-		"G4RC0NB0T": slack.User{
+		"G4RC0NB0T": Patron{
 			ID:   g.SelfID,
 			Name: "garcon",
 		},
 	}
-	g.InterlocutorID = dummyUserID
-	m := slack.Msg{
+	m := Message{
 		User:    dummyUserID,
 		Channel: "whocares",
 	}
-	return g, m
+	s := g.Sessions.SessionFor(m.Channel, "")
+	s.InterlocutorID = dummyUserID
+	return g, s, m
 }
 
 func TestGarconConstructsWithoutError(t *testing.T) {
 	g := NewGarcon()
-	g.Stage = "test"
+	g.Sessions.SessionFor("whocares", "").Stage = "test"
 }
 
-func TestGarconReset(t *testing.T) {
-	expected := Garcon{
-		Stage:                "uninitiated",
-		InterlocutorID:       "",
-		RequestedRestauraunt: "",
-		Order:                map[string]string{},
+func TestSessionReset(t *testing.T) {
+	expected := Session{
+		Key:                 "whocares",
+		Channel:             "whocares",
+		Stage:               "uninitiated",
+		InterlocutorID:      "",
+		RequestedRestaurant: "",
+		ActualRestaurant:    &Restaurant{},
+		Order:               Order{},
 	}
 
-	actual := Garcon{
-		Stage:                "whatever",
-		InterlocutorID:       "who cares",
-		RequestedRestauraunt: "Greasy Gus's Frog Emporium",
-		Order: map[string]string{
-			"Gary": "frog balls",
+	actual := Session{
+		Key:                 "whocares",
+		Channel:             "whocares",
+		Stage:               "whatever",
+		InterlocutorID:      "who cares",
+		RequestedRestaurant: "Greasy Gus's Frog Emporium",
+		Order: Order{
+			"Gary": []LineItem{LineItem{Quantity: 2, Name: "frog balls"}},
 		},
 	}
 	actual.Reset()
@@ -110,7 +119,7 @@ func TestPossibleValidCommands(t *testing.T) {
 }
 
 func TestGarconRespondsToHello(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
+	g, _, m := returnGarconAndEmptyMessage()
 	m.Text = "oh, garçon?"
 	messages := g.RespondToMessage(m)
 	assert.Equal(t, 1, len(messages))
@@ -118,27 +127,27 @@ func TestGarconRespondsToHello(t *testing.T) {
 }
 
 func TestGarconUnderstandsWhenAddressed(t *testing.T) {
-	g, _ := returnGarconAndEmptyMessage()
-	validMessages := []slack.Msg{
-		slack.Msg{Text: "ok <@G4RC0NB0T>, "},
-		slack.Msg{Text: "okay <@G4RC0NB0T>, "},
-		slack.Msg{Text: "ok, <@G4RC0NB0T>, "},
-		slack.Msg{Text: "okay, <@G4RC0NB0T>, "},
+	g, _, _ := returnGarconAndEmptyMessage()
+	validMessages := []Message{
+		Message{Text: "ok <@G4RC0NB0T>, "},
+		Message{Text: "okay <@G4RC0NB0T>, "},
+		Message{Text: "ok, <@G4RC0NB0T>, "},
+		Message{Text: "okay, <@G4RC0NB0T>, "},
 
-		slack.Msg{Text: "ok <@G4RC0NB0T>: "},
-		slack.Msg{Text: "okay <@G4RC0NB0T>: "},
-		slack.Msg{Text: "ok, <@G4RC0NB0T>: "},
-		slack.Msg{Text: "okay, <@G4RC0NB0T>: "},
+		Message{Text: "ok <@G4RC0NB0T>: "},
+		Message{Text: "okay <@G4RC0NB0T>: "},
+		Message{Text: "ok, <@G4RC0NB0T>: "},
+		Message{Text: "okay, <@G4RC0NB0T>: "},
 
-		slack.Msg{Text: "ok <@G4RC0NB0T>,"},
-		slack.Msg{Text: "okay <@G4RC0NB0T>,"},
-		slack.Msg{Text: "ok, <@G4RC0NB0T>:"},
-		slack.Msg{Text: "okay, <@G4RC0NB0T>:"},
+		Message{Text: "ok <@G4RC0NB0T>,"},
+		Message{Text: "okay <@G4RC0NB0T>,"},
+		Message{Text: "ok, <@G4RC0NB0T>:"},
+		Message{Text: "okay, <@G4RC0NB0T>:"},
 
-		slack.Msg{Text: "ok <@G4RC0NB0T>"},
-		slack.Msg{Text: "okay <@G4RC0NB0T>"},
-		slack.Msg{Text: "ok, <@G4RC0NB0T>"},
-		slack.Msg{Text: "okay, <@G4RC0NB0T>"},
+		Message{Text: "ok <@G4RC0NB0T>"},
+		Message{Text: "okay <@G4RC0NB0T>"},
+		Message{Text: "ok, <@G4RC0NB0T>"},
+		Message{Text: "okay, <@G4RC0NB0T>"},
 	}
 
 	for _, m := range validMessages {
@@ -147,15 +156,15 @@ func TestGarconUnderstandsWhenAddressed(t *testing.T) {
 }
 
 func TestGarconDoesNotRespondWhenUninitiated(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
+	g, _, m := returnGarconAndEmptyMessage()
 	m.Text = "Oh yeah...oh yeah...oh yeah...The moon...beautiful...the sun...even more beautiful"
 	messages := g.RespondToMessage(m)
 	assert.Equal(t, 0, len(messages))
 }
 
 func TestGarconRespondsToRestaurantRequest(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
-	g.Stage = "prompted"
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "prompted"
 	m.Text = "We would like to order from the Chili's on 45th & Lamar"
 
 	messages := g.RespondToMessage(m)
@@ -164,13 +173,13 @@ func TestGarconRespondsToRestaurantRequest(t *testing.T) {
 }
 
 func TestGarconRespondsToNonInterlocutor(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
-	g.InterlocutorID = "SOMEJERK"
-	g.Patrons["SOMEJERK"] = slack.User{
+	g, s, m := returnGarconAndEmptyMessage()
+	s.InterlocutorID = "SOMEJERK"
+	g.Patrons["SOMEJERK"] = Patron{
 		ID:   "SOMEJERK",
 		Name: "whocares",
 	}
-	g.Stage = "prompted"
+	s.Stage = "prompted"
 	m.Text = "We would like to order from the Chili's on 45th & Lamar"
 
 	messages := g.RespondToMessage(m)
@@ -178,30 +187,30 @@ func TestGarconRespondsToNonInterlocutor(t *testing.T) {
 }
 
 func TestGarconRespondsToInvalidResponseAfterPrompt(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
-	g.Stage = "prompted"
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "prompted"
 	m.Text = "I WANT A BIG OL' HEAP O' CHILI RIGHT NOW GOL DURNIT!"
 
 	messages := g.RespondToMessage(m)
 	assert.Equal(t, 1, len(messages))
-	assert.Equal(t, "I'm sorry, @brainfart, I couldn't understand what you said. Here are some things I might understand:\n • We'd like to place an order for the Chili's at 45th & Lamar\n • We would like to order from the Chili's at 45th & Lamar\n • @garcon, go away\n • @garcon, help!\n", messages[0].Text)
+	assert.Equal(t, "I'm sorry, @brainfart, I couldn't understand what you said. Here are some things I might understand:\n • We'd like to place an order for the Chili's at 45th & Lamar\n • We would like to order from the Chili's at 45th & Lamar\n • @garcon, go away\n • @garcon, help!\n • @garcon, what do I owe?\n • @garcon, alice paid me back $12\n", messages[0].Text)
 }
 
 func TestGarconRespondsToOrderRequest(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
-	g.Stage = "ordering"
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
 	m.Text = "<@G4RC0NB0T> I'll have a bananas foster"
 
 	messages := g.RespondToMessage(m)
 	assert.Equal(t, 1, len(messages))
-	assert.Equal(t, "Okay @brainfart, I've got your order.", messages[0].Text)
+	assert.Equal(t, "Okay @brainfart, I've added 1x bananas foster to your order.", messages[0].Text)
 }
 
 func TestGarconRespondsToOrderConfirmationRequest(t *testing.T) {
-	g, m := returnGarconAndEmptyMessage()
+	g, s, m := returnGarconAndEmptyMessage()
 
-	g.Stage = "ordering"
-	g.RequestedRestauraunt = "Gary's Racoon Hut"
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
 	m.Text = "<@G4RC0NB0T> I'll have a peach melba"
 	_ = g.RespondToMessage(m)
 
@@ -213,16 +222,359 @@ func TestGarconRespondsToOrderConfirmationRequest(t *testing.T) {
 		t.FailNow()
 	}
 	assert.Equal(t, "Alright, then!", messages[0].Text)
-	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\nBrainfart: a peach melba\n```", messages[1].Text)
+	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\nBrainfart: 1x peach melba\n```", messages[1].Text)
 	assert.Equal(t, "Is that correct?", messages[2].Text)
 }
 
+func TestGarconRemembersTheRestaurantAfterStartingOver(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+
+	s.Stage = "confirmation"
+	s.RequestedRestaurant = "gary's"
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba"})
+	m.Text = "no"
+	g.RespondToMessage(m)
+	assert.Equal(t, "ordering", s.Stage)
+
+	m.Text = ""
+	m.Command = "status"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\n\n```", messages[0].Text)
+}
+
+func TestGarconKeepsSeparateSessionsPerChannel(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
+
+	m.Channel = "someotherchannel"
+	m.Text = "oh, garçon?"
+	messages := g.RespondToMessage(m)
+
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "someotherchannel", messages[0].Channel)
+	assert.Equal(t, "prompted", g.Sessions.SessionFor("someotherchannel", "").Stage)
+	assert.Equal(t, "ordering", s.Stage)
+	assert.Equal(t, "Gary's Racoon Hut", s.RequestedRestaurant)
+}
+
+func TestSessionForFallsBackToChannelSession(t *testing.T) {
+	sm := NewSessionManager()
+	s := sm.SessionFor("whocares", "")
+	assert.Equal(t, s, sm.SessionFor("whocares", "1234.5678"))
+}
+
 // func TestGarconRespondsToOrderConfirmation(t *testing.T) {
-// 	g, m := returnGarconAndEmptyMessage()
-// 	g.Stage = "confirmation"
+// 	g, s, m := returnGarconAndEmptyMessage()
+// 	s.Stage = "confirmation"
 // 	m.Text = "yep!"
 
 // 	messages := g.RespondToMessage(m)
 // 	assert.Equal(t, 1, len(messages))
 // 	assert.Equal(t, "Okay, I'll send this order off!", messages[0].Text)
 // }
+
+type flakyError struct{}
+
+func (e flakyError) Error() string   { return "the courier tripped over a cat" }
+func (e flakyError) Temporary() bool { return true }
+
+type flakyDeliveryProvider struct {
+	fakeDeliveryProvider
+	failures int
+}
+
+func (f *flakyDeliveryProvider) CreateDelivery(manifest *Manifest, from, to *DeliverySpot, quote *DeliveryQuote) (*Delivery, error) {
+	if f.failures > 0 {
+		f.failures--
+		return nil, flakyError{}
+	}
+	return f.fakeDeliveryProvider.CreateDelivery(manifest, from, to, quote)
+}
+
+func TestGarconRetriesTemporaryDeliveryFailures(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.DeliveryBackoff = 0
+	g.DeliveryProvider = &flakyDeliveryProvider{newFakeDeliveryProvider(), 2}
+	s.Stage = "confirmation"
+	m.Text = "yep"
+	g.RespondToMessage(m)
+	assert.Equal(t, "approval", s.Stage)
+
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "Okay, I'll send this order off!", messages[0].Text)
+	assert.Equal(t, "dispatched", s.Stage)
+}
+
+func TestWithRetriesGivesUpAtTheTimeout(t *testing.T) {
+	tries := 0
+	err := withRetries(5, 20*time.Millisecond, 30*time.Millisecond, func() error {
+		tries++
+		return flakyError{}
+	})
+	assert.Equal(t, flakyError{}, err)
+	assert.Equal(t, 2, tries, "the second wait would've gone past the timeout")
+}
+
+func TestGarconKeepsOrderWhenDeliveryFails(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.DeliveryBackoff = 0
+	g.DeliveryProvider = &flakyDeliveryProvider{newFakeDeliveryProvider(), 5}
+	s.Stage = "confirmation"
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba"})
+	m.Text = "yep"
+	g.RespondToMessage(m)
+
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "I'm having some problems placing this order. I wasn't able to create the delivery: the courier tripped over a cat\n@brainfart, say \"@garcon, try again\" to have me retry, or \"@garcon, abandon the order\" to give up on it.", messages[0].Text)
+	assert.Equal(t, "approval", s.Stage)
+	assert.Equal(t, []LineItem{LineItem{Quantity: 1, Name: "peach melba"}}, s.Order["brainfart"])
+
+	m.Text = "<@G4RC0NB0T>, try again"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay, I'll send this order off!", messages[0].Text)
+	assert.Equal(t, "dispatched", s.Stage)
+}
+
+func TestGarconOnlyLetsInterlocutorAbandonFailedOrder(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+	s.Stage = "confirmation"
+	s.DeliveryError = "I wasn't able to create the delivery: the courier tripped over a cat"
+
+	m.User = "SOMEJERK"
+	m.Text = "<@G4RC0NB0T>, abandon the order"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @whocares, only @brainfart can do that for this order.", messages[0].Text)
+	assert.Equal(t, "confirmation", s.Stage)
+
+	m.User = s.InterlocutorID
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay, I've abandoned this order. Nothing was sent.", messages[0].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+}
+
+type listRestaurantFinder []Restaurant
+
+func (l listRestaurantFinder) FindRestaurants(query, near string) ([]Restaurant, error) {
+	return l, nil
+}
+
+func TestGarconAsksWhichRestaurantWhenSeveralMatch(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.RestaurantFinder = listRestaurantFinder{
+		Restaurant{PlaceID: "north", Name: "Chili's", Address: "4511 N Lamar Blvd", Distance: 1609.344},
+		Restaurant{PlaceID: "south", Name: "Chili's", Address: "2200 S Lamar Blvd"},
+	}
+	s.Stage = "prompted"
+	m.Text = "We'd like to order from Chili's"
+
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "I found a few places matching \"Chili's\". Which one did you mean, @brainfart?\n1. Chili's, 4511 N Lamar Blvd (1.0 mi away)\n2. Chili's, 2200 S Lamar Blvd\nSay the number of the one you'd like, react to this message with its number, or say \"none of those\".", messages[0].Text)
+	assert.Equal(t, "choosing", s.Stage)
+
+	m.Text = "7"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "choosing", s.Stage)
+
+	m.Text = ""
+	m.Reaction = "two"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay, what would everyone like from Chili's?", messages[0].Text)
+	assert.Equal(t, "ordering", s.Stage)
+	assert.Equal(t, "south", s.ActualRestaurant.PlaceID)
+}
+
+func TestGarconHandlesNoMatchingRestaurants(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.RestaurantFinder = listRestaurantFinder{}
+	s.Stage = "prompted"
+	m.Text = "We'd like to order from Chili's"
+
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, I couldn't find any open restaurants nearby matching \"Chili's\". Is there somewhere else you'd like to order from?", messages[0].Text)
+	assert.Equal(t, "prompted", s.Stage)
+}
+
+func TestGarconLetsPeopleChangeTheirOrders(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "banana"})
+	s.Order.Add("brainfart", LineItem{Quantity: 2, Name: "fries"})
+
+	m.Text = "<@G4RC0NB0T>, change my banana to an apple (sliced)"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've changed 1x banana to 1x apple (sliced).", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T>: remove the fries"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've removed 2x fries from your order.", messages[0].Text)
+	assert.Equal(t, []LineItem{LineItem{Quantity: 1, Name: "apple", Notes: "sliced"}}, s.Order["brainfart"])
+
+	m.Text = "<@G4RC0NB0T>: remove the fries"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, I don't see fries in your order.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T> I'll have a lemon drop martini"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added 1x lemon drop martini to your order.", messages[0].Text)
+
+	m.Text = "ok <@G4RC0NB0T>, scratch my order"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've taken you off the order.", messages[0].Text)
+	assert.Equal(t, 0, len(s.Order))
+}
+
+func TestGarconOffersButtonsWhenInteractive(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+	g.Interactive = true
+	g.RestaurantFinder = listRestaurantFinder{
+		Restaurant{PlaceID: "north", Name: "Chili's", Address: "4511 N Lamar Blvd"},
+		Restaurant{PlaceID: "south", Name: "Chili's", Address: "2200 S Lamar Blvd"},
+	}
+	s.Stage = "prompted"
+	m.Text = "We'd like to order from Chili's"
+
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, []Choice{Choice{Text: "1. Chili's", Value: "1"}, Choice{Text: "2. Chili's", Value: "2"}}, messages[0].Options)
+
+	m.Text = ""
+	m.Action = "2"
+	m.User = "SOMEJERK"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @whocares, only @brainfart can do that for this order.", messages[0].Text)
+	assert.Equal(t, "choosing", s.Stage)
+
+	m.User = s.InterlocutorID
+	g.RespondToMessage(m)
+	assert.Equal(t, "south", s.ActualRestaurant.PlaceID)
+
+	m.Action = "1"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, 0, len(messages), "clicks on stale buttons should be ignored")
+
+	m.Action = ""
+	m.Text = "I think we're ready"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, 3, len(messages[2].Buttons))
+
+	m.Text = ""
+	m.Action = confirmAction
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, 3, len(messages[0].Buttons))
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay, I'll send this order off!", messages[0].Text)
+}
+
+func TestGarconOnlyLetsInterlocutorConfirm(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+	s.Stage = "confirmation"
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba"})
+
+	m.User = "SOMEJERK"
+	for _, text := range []string{"yes", "no"} {
+		m.Text = text
+		messages := g.RespondToMessage(m)
+		assert.Equal(t, "I'm sorry, @whocares, only @brainfart can do that for this order.", messages[0].Text, text)
+		assert.Equal(t, "confirmation", s.Stage, text)
+	}
+
+	m.User = s.InterlocutorID
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	assert.Equal(t, "approval", s.Stage)
+}
+
+func TestGarconTreatsClicksLikeTypedAnswers(t *testing.T) {
+	answers := map[string]string{
+		confirmAction:   "yes",
+		startOverAction: "no",
+		cancelAction:    "<@G4RC0NB0T> go away",
+	}
+	for action, text := range answers {
+		for _, user := range []string{"L0LWTFBBQ", "SOMEJERK"} {
+			outcomes := [][]string{}
+			for _, clicked := range []bool{true, false} {
+				g, s, m := returnGarconAndEmptyMessage()
+				g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+				s.Stage = "confirmation"
+				s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba"})
+				m.User = user
+				if clicked {
+					m.Action = action
+				} else {
+					m.Text = text
+				}
+				messages := g.RespondToMessage(m)
+				outcomes = append(outcomes, []string{s.Stage, messages[0].Text})
+			}
+			assert.Equal(t, outcomes[1], outcomes[0], "%v clicking %v", user, action)
+		}
+	}
+}
+
+func TestGarconOnlyOffersTextChoicesWhenNotInteractive(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	m.Text = "I think we're ready"
+
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Is that correct?", messages[2].Text)
+	assert.Equal(t, 0, len(messages[2].Buttons))
+}
+
+func TestGarconMovesOrdersIntoThreads(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.InterlocutorID = ""
+	m.Text = "oh, garçon?"
+
+	messages := g.RespondToMessage(m)
+	assert.True(t, messages[0].StartsThread)
+	assert.Equal(t, "", g.Threaded(messages[0]).ThreadTimestamp)
+	g.ResponseSent(messages[0], "1234.5678")
+	assert.Equal(t, "whocares/1234.5678", s.Key)
+	assert.NotEqual(t, s, g.Sessions.SessionFor("whocares", ""), "the channel should be free for another order")
+
+	m.ThreadTimestamp = "1234.5678"
+	m.Text = "We'd like to order from Gary's Racoon Hut"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "1234.5678", g.Threaded(messages[0]).ThreadTimestamp)
+
+	m.Text = "<@G4RC0NB0T> I'll have a banana"
+	g.RespondToMessage(m)
+	m.Text = "I think we're ready"
+	g.RespondToMessage(m)
+	m.Text = "yep"
+	g.RespondToMessage(m)
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "1234.5678", g.Threaded(messages[0]).ThreadTimestamp)
+	assert.Equal(t, "@brainfart just sent off an order from Gary's Racoon Hut: 1 item for Brainfart.", messages[1].Text)
+	assert.Equal(t, "", g.Threaded(messages[1]).ThreadTimestamp)
+
+	assert.Equal(t, 2, len(g.Sessions.All()))
+
+	messages = g.DeliveryUpdated(&Delivery{ID: s.Delivery.ID, Status: "delivered"})
+	assert.Equal(t, "Our order from Gary's Racoon Hut has arrived. Enjoy!", messages[0].Text)
+	assert.Equal(t, "", g.Threaded(messages[0]).ThreadTimestamp)
+	assert.Equal(t, 1, len(g.Sessions.All()), "the finished thread's session should be forgotten")
+}
+
+func TestGarconCarriesOnInTheThreadItWasAskedIn(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.InterlocutorID = ""
+	m.ThreadTimestamp = "1234.5678"
+	m.Text = "oh, garçon?"
+
+	messages := g.RespondToMessage(m)
+	assert.False(t, messages[0].StartsThread)
+	assert.Equal(t, "1234.5678", g.Threaded(messages[0]).ThreadTimestamp)
+	assert.Equal(t, s, g.Sessions.SessionFor("whocares", "1234.5678"))
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	usualOrderPattern = "^" + atGarconPattern + "((I would|I'd) like|I'll have|give me) (my|the) usual"
+	sameOrderPattern  = "^" + atGarconPattern + "(I'll have |I'd like |give me )?(the )?same( thing| order)? as (?P<when>last time|yesterday|last (monday|tuesday|wednesday|thursday|friday|saturday|sunday))"
+)
+
+// OrderRecord is an order that was sent off, kept around after its session's moved on
+type OrderRecord struct {
+	Restaurant   Restaurant
+	Channel      string
+	Interlocutor string
+	Order        Order
+	// Cost is everything the order cost, in the smallest unit of currency
+	Cost       int
+	DeliveryID string
+	Started    time.Time
+	Placed     time.Time
+	// Finished is when the delivery was over, and Status is how it ended up
+	Finished time.Time `json:",omitempty"`
+	Status   string
+	// Events is what happened to the order along the way, including how it ended, since
+	// the session forgets once it's reset
+	Events []SessionEvent `json:",omitempty"`
+}
+
+// OrderHistory keeps every order Garcon's sent off, so people can order the same
+// thing again
+type OrderHistory struct {
+	// Path is where the history is saved, if anywhere
+	Path string `json:"-"`
+
+	mu     sync.Mutex
+	Orders []OrderRecord
+}
+
+// NewOrderHistory constructs an OrderHistory that's saved to the given path, picking up
+// whatever was saved there before. An empty path keeps the history in memory only.
+func NewOrderHistory(path string) (*OrderHistory, error) {
+	h := &OrderHistory{Path: path}
+	if len(path) == 0 {
+		return h, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %v: %v", path, err)
+	}
+	if err := loadJSON(path, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *OrderHistory) save() error {
+	if len(h.Path) == 0 {
+		return nil
+	}
+	return saveJSON(h.Path, h)
+}
+
+// Add puts an order in the history
+func (h *OrderHistory) Add(r OrderRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Orders = append(h.Orders, r)
+	return h.save()
+}
+
+// Finish notes how the order with the given delivery ended up, and everything that
+// happened to it
+func (h *OrderHistory) Finish(deliveryID, status string, when time.Time, events []SessionEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.Orders {
+		if h.Orders[i].DeliveryID == deliveryID {
+			h.Orders[i].Status = status
+			h.Orders[i].Finished = when
+			h.Orders[i].Events = append([]SessionEvent{}, events...)
+			return h.save()
+		}
+	}
+	return nil
+}
+
+// PastOrdersFrom returns what a person ordered from a restaurant each time they
+// ordered from it, most recent first, along with when they ordered it. Canceled
+// orders don't count.
+func (h *OrderHistory) PastOrdersFrom(r *Restaurant, person string) (parts [][]LineItem, placed []time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.Orders) - 1; i >= 0; i-- {
+		o := h.Orders[i]
+		if !sameRestaurant(&o.Restaurant, r) || o.Status == "canceled" || len(o.Order[person]) == 0 {
+			continue
+		}
+		parts = append(parts, o.Order[person])
+		placed = append(placed, o.Placed)
+	}
+	return
+}
+
+func sameRestaurant(a, b *Restaurant) bool {
+	if len(a.PlaceID) > 0 && len(b.PlaceID) > 0 {
+		return a.PlaceID == b.PlaceID
+	}
+	return strings.ToLower(a.Name) == strings.ToLower(b.Name)
+}
+
+// recordOrder puts a dispatched order in the history
+func (g *Garcon) recordOrder(s *Session) {
+	r := OrderRecord{
+		Restaurant:   *s.ActualRestaurant,
+		Channel:      s.Channel,
+		Interlocutor: g.Patrons[s.InterlocutorID].Name,
+		Order:        s.Order,
+		Cost:         g.orderCost(s),
+		DeliveryID:   s.Delivery.ID,
+		Placed:       g.now(),
+		Status:       s.Delivery.Status,
+	}
+	if len(s.History) > 0 {
+		r.Started = s.History[0].Time
+	}
+	if err := g.OrderHistory.Add(r); err != nil {
+		log.Printf("I wasn't able to add the order for %v to the history:\n\t%v\n", s.Key, err)
+	}
+}
+
+// orderFinished notes how a dispatched order ended up in the history
+func (g *Garcon) orderFinished(s *Session, d *Delivery, when time.Time) {
+	if err := g.OrderHistory.Finish(d.ID, d.Status, when, s.History); err != nil {
+		log.Printf("I wasn't able to update the history for %v:\n\t%v\n", s.Key, err)
+	}
+}
+
+// reorderRequested returns whether a message is someone asking for something they've
+// ordered before
+func (g *Garcon) reorderRequested(m Message) bool {
+	return g.MessageAddressesGarcon(m) && (stringFitsPattern(usualOrderPattern, m.Text) || stringFitsPattern(sameOrderPattern, m.Text))
+}
+
+// usualOrder picks out whatever someone's ordered most often, going with the most
+// recent when there's a tie
+func usualOrder(parts [][]LineItem) []LineItem {
+	counts := map[string]int{}
+	var usual []LineItem
+	for _, part := range parts {
+		key := strings.ToLower(describeItems(part))
+		counts[key]++
+		if usual == nil || counts[key] > counts[strings.ToLower(describeItems(usual))] {
+			usual = part
+		}
+	}
+	return usual
+}
+
+// orderOn picks out what someone ordered on the day described by "last time",
+// "yesterday" or "last friday"
+func orderOn(when string, parts [][]LineItem, placed []time.Time, now time.Time) []LineItem {
+	when = strings.ToLower(when)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for i, part := range parts {
+		day := placed[i].In(now.Location())
+		switch {
+		case when == "last time":
+			return part
+		case when == "yesterday":
+			if day.Before(today) && !day.Before(today.AddDate(0, 0, -1)) {
+				return part
+			}
+		case day.Before(today) && strings.ToLower(day.Weekday().String()) == strings.TrimPrefix(when, "last "):
+			return part
+		}
+	}
+	return nil
+}
+
+// reorder puts something someone's ordered from the restaurant before back on the order
+func (g *Garcon) reorder(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	parts, placed := g.OrderHistory.PastOrdersFrom(s.ActualRestaurant, name)
+
+	var items []LineItem
+	what := "your usual"
+	if stringFitsPattern(usualOrderPattern, m.Text) {
+		items = usualOrder(parts)
+	} else {
+		match, _ := findElementsInString(sameOrderPattern, []string{"when"}, m.Text)
+		when := strings.ToLower(match["when"])
+		items = orderOn(when, parts, placed, g.now())
+		what = fmt.Sprintf("what you had %v", when)
+		if when != "last time" && when != "yesterday" {
+			what = fmt.Sprintf("what you had on %v", strings.Title(strings.TrimPrefix(when, "last ")))
+		}
+	}
+
+	if len(items) == 0 {
+		t := fmt.Sprintf("I'm sorry, @%v, I don't have a record of %v from %v.", name, what, s.ActualRestaurant.Name)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+
+	// the menu might have changed since, so anything that's no longer on it gets the
+	// same treatment as if it had just been typed in
+	var added []LineItem
+	var problems []OutgoingMessage
+	for _, item := range items {
+		if s.Menu != nil {
+			matched, responses, ok := g.checkAgainstMenu(s, m, item, "")
+			if !ok {
+				problems = append(problems, responses...)
+				continue
+			}
+			item = matched
+		}
+		s.Order.Add(name, item)
+		added = append(added, item)
+	}
+	if len(added) == 0 {
+		return problems
+	}
+	t := fmt.Sprintf("Okay @%v, I've added %v to your order: %v.", name, what, describeItems(added))
+	return append([]OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}, problems...)
+}
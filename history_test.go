@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func returnGarconWithHistory() (*Garcon, *Session, Message) {
+	g, s, m := returnGarconAndEmptyMessage()
+	tuesday := time.Date(2016, 7, 5, 11, 30, 0, 0, time.UTC)
+	g.now = func() time.Time { return tuesday }
+	s.Stage = "ordering"
+	s.InterlocutorID = m.User
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+
+	gary := Restaurant{Name: "Gary's Racoon Hut"}
+	g.OrderHistory.Add(OrderRecord{Restaurant: gary, Placed: tuesday.AddDate(0, 0, -11), Status: "delivered", Order: Order{
+		"brainfart": []LineItem{LineItem{Quantity: 2, Name: "taco"}},
+	}})
+	g.OrderHistory.Add(OrderRecord{Restaurant: gary, Placed: tuesday.AddDate(0, 0, -4), Status: "delivered", Order: Order{
+		"brainfart": []LineItem{LineItem{Quantity: 1, Name: "tuna melt"}},
+	}})
+	g.OrderHistory.Add(OrderRecord{Restaurant: Restaurant{Name: "Chili's"}, Placed: tuesday.AddDate(0, 0, -1), Status: "delivered", Order: Order{
+		"brainfart": []LineItem{LineItem{Quantity: 1, Name: "chips & queso"}},
+	}})
+	g.OrderHistory.Add(OrderRecord{Restaurant: gary, Placed: tuesday.AddDate(0, 0, -1), Status: "delivered", Order: Order{
+		"brainfart": []LineItem{LineItem{Quantity: 2, Name: "taco"}},
+	}})
+	g.OrderHistory.Add(OrderRecord{Restaurant: gary, Placed: tuesday.AddDate(0, 0, -1), Status: "canceled", Order: Order{
+		"brainfart": []LineItem{LineItem{Quantity: 1, Name: "horchata"}},
+	}})
+	return g, s, m
+}
+
+func TestGarconRecordsDispatchedOrders(t *testing.T) {
+	g, s, m := returnGarconWithSplitOrder()
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+
+	if assert.Equal(t, 1, len(g.OrderHistory.Orders)) {
+		r := g.OrderHistory.Orders[0]
+		assert.Equal(t, "Gary's Racoon Hut", r.Restaurant.Name)
+		assert.Equal(t, "brainfart", r.Interlocutor)
+		assert.Equal(t, []string{"brainfart", "gary"}, r.Order.People())
+		assert.Equal(t, s.Delivery.ID, r.DeliveryID)
+		assert.Equal(t, g.now(), r.Placed)
+	}
+
+	s.Reset()
+	s.Stage = "ordering"
+	s.InterlocutorID = m.User
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+	m.Text = "<@G4RC0NB0T> I'll have my usual"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added your usual to your order: 1x peach melba.", messages[0].Text)
+	assert.Equal(t, 500, s.Order["brainfart"][0].Price)
+}
+
+func TestGarconReordersFromHistory(t *testing.T) {
+	g, s, m := returnGarconWithHistory()
+
+	m.Text = "<@G4RC0NB0T> same as last Friday"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added what you had on Friday to your order: 1x tuna melt.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T> I'd like the same thing as yesterday"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added what you had yesterday to your order: 2x taco.", messages[0].Text, "canceled orders and other restaurants shouldn't count")
+
+	m.Text = "<@G4RC0NB0T> give me the usual"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added your usual to your order: 2x taco.", messages[0].Text)
+	assert.Equal(t, "1x tuna melt, 4x taco", describeItems(s.Order["brainfart"]))
+
+	m.Text = "<@G4RC0NB0T> same as last Sunday"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, I don't have a record of what you had on Sunday from Gary's Racoon Hut.", messages[0].Text)
+
+	g.Patrons["G4RYG4RY1"] = Patron{ID: "G4RYG4RY1", Name: "gary"}
+	m.User = "G4RYG4RY1"
+	m.Text = "<@G4RC0NB0T> I'll have my usual"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @gary, I don't have a record of your usual from Gary's Racoon Hut.", messages[0].Text)
+}
+
+func TestUsualOrder(t *testing.T) {
+	taco := []LineItem{LineItem{Quantity: 2, Name: "taco"}}
+	melt := []LineItem{LineItem{Quantity: 1, Name: "tuna melt"}}
+	assert.Equal(t, melt, usualOrder([][]LineItem{melt, taco}), "the most recent should win a tie")
+	assert.Equal(t, taco, usualOrder([][]LineItem{melt, taco, taco}))
+	assert.Nil(t, usualOrder(nil))
+}
+
+func TestOrderHistoryIsSaved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "garcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "records", "orders.json")
+	placed := time.Date(2016, 7, 4, 11, 30, 0, 0, time.UTC)
+
+	h, err := NewOrderHistory(path)
+	assert.Nil(t, err)
+	assert.Nil(t, h.Add(OrderRecord{Restaurant: Restaurant{Name: "Gary's Racoon Hut"}, DeliveryID: "del_123", Placed: placed, Status: "pending", Order: Order{
+		"brainfart": []LineItem{LineItem{Quantity: 1, Name: "peach melba", Price: 500}},
+	}}))
+	assert.Nil(t, h.Finish("del_123", "delivered", placed.Add(time.Hour), []SessionEvent{SessionEvent{Time: placed, Description: "the order was sent off as delivery del_123"}}))
+
+	h, err = NewOrderHistory(path)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(h.Orders)) {
+		assert.Equal(t, "delivered", h.Orders[0].Status)
+		assert.True(t, h.Orders[0].Finished.Equal(placed.Add(time.Hour)))
+		assert.Equal(t, "the order was sent off as delivery del_123", h.Orders[0].Events[0].Description)
+	}
+	parts, _ := h.PastOrdersFrom(&Restaurant{Name: "gary's racoon hut"}, "brainfart")
+	assert.Equal(t, [][]LineItem{[]LineItem{LineItem{Quantity: 1, Name: "peach melba", Price: 500}}}, parts)
+}
+
+func TestGarconChecksReordersAgainstTheMenu(t *testing.T) {
+	g, s, m := returnGarconWithHistory()
+	s.Menu = returnTestMenu()
+	s.Menu.Items = s.Menu.Items[1:] // the tuna melt's gone
+
+	m.Text = "<@G4RC0NB0T> give me the usual"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added your usual to your order: 2x Taco.", messages[0].Text)
+	assert.Equal(t, 300, s.Order["brainfart"][0].Price, "prices should be what the menu says now")
+
+	m.Text = "<@G4RC0NB0T> same as last Friday"
+	messages = g.RespondToMessage(m)
+	if assert.Equal(t, 1, len(messages)) {
+		assert.Equal(t, "I'm sorry, @brainfart, I don't see anything like \"tuna melt\" on the menu at Gary's Racoon Hut.", messages[0].Text)
+	}
+	assert.Equal(t, "2x Taco", describeItems(s.Order["brainfart"]))
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	whatDoIOwePattern = "^" + atGarconPattern + "(what|how much) do I owe"
+	paidBackPattern   = "^" + atGarconPattern + "(<@(?P<mention>[0-9A-Z]{9})>|@?(?P<name>[a-z0-9._-]+)) paid me back \\$?(?P<amount>[0-9]+(\\.[0-9]{1,2})?)"
+)
+
+// LedgerEntry is money one person owes another, or paid them back, which is just the
+// same thing the other way around
+type LedgerEntry struct {
+	Time    time.Time
+	Channel string
+	// From owes To the Amount, in the smallest unit of currency
+	From        string
+	To          string
+	Amount      int
+	Description string
+	// Reference is the delivery the entry came from, if it came from one
+	Reference string `json:",omitempty"`
+}
+
+// Debt is what one person owes another once everything between them is added up
+type Debt struct {
+	From   string
+	To     string
+	Amount int
+}
+
+// Ledger keeps track of who owes whom for the orders they've been on together
+type Ledger struct {
+	// Path is where the ledger is saved, if anywhere
+	Path string `json:"-"`
+
+	mu      sync.Mutex
+	Entries []LedgerEntry
+	// SettledUp is the month of the last settle-up summary
+	SettledUp string
+}
+
+// NewLedger constructs a Ledger that's saved to the given path, picking up whatever
+// was saved there before. An empty path keeps the ledger in memory only.
+func NewLedger(path string) (*Ledger, error) {
+	l := &Ledger{Path: path}
+	if len(path) == 0 {
+		return l, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %v: %v", path, err)
+	}
+	if err := loadJSON(path, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Ledger) save() error {
+	if len(l.Path) == 0 {
+		return nil
+	}
+	return saveJSON(l.Path, l)
+}
+
+// Add writes entries into the ledger
+func (l *Ledger) Add(entries ...LedgerEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Entries = append(l.Entries, entries...)
+	return l.save()
+}
+
+// Debts adds up everything in the ledger into who owes whom, leaving out anyone
+// who's square, with the biggest debts first
+func (l *Ledger) Debts() []Debt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// net is keyed by pairs of people in alphabetical order, and is positive when the
+	// first owes the second
+	net := map[[2]string]int{}
+	for _, e := range l.Entries {
+		if e.From < e.To {
+			net[[2]string{e.From, e.To}] += e.Amount
+		} else {
+			net[[2]string{e.To, e.From}] -= e.Amount
+		}
+	}
+
+	debts := []Debt{}
+	for pair, amount := range net {
+		switch {
+		case amount > 0:
+			debts = append(debts, Debt{From: pair[0], To: pair[1], Amount: amount})
+		case amount < 0:
+			debts = append(debts, Debt{From: pair[1], To: pair[0], Amount: -amount})
+		}
+	}
+	sort.Slice(debts, func(i, j int) bool {
+		if debts[i].Amount != debts[j].Amount {
+			return debts[i].Amount > debts[j].Amount
+		}
+		return debts[i].From+debts[i].To < debts[j].From+debts[j].To
+	})
+	return debts
+}
+
+// DebtsOf returns every debt the given person is on either end of
+func (l *Ledger) DebtsOf(person string) []Debt {
+	debts := []Debt{}
+	for _, d := range l.Debts() {
+		if d.From == person || d.To == person {
+			debts = append(debts, d)
+		}
+	}
+	return debts
+}
+
+// recordDebts notes that everyone on a dispatched order owes whoever placed it for
+// their share
+func (g *Garcon) recordDebts(s *Session) {
+	payer := g.Patrons[s.InterlocutorID].Name
+	entries := []LedgerEntry{}
+	for _, sh := range g.shares(s) {
+		if sh.Person == payer || sh.Total() == 0 {
+			continue
+		}
+		entries = append(entries, LedgerEntry{
+			Time:        g.now(),
+			Channel:     s.Channel,
+			From:        sh.Person,
+			To:          payer,
+			Amount:      sh.Total(),
+			Description: fmt.Sprintf("their share of the order from %v", s.ActualRestaurant.Name),
+			Reference:   s.Delivery.ID,
+		})
+	}
+	if err := g.Ledger.Add(entries...); err != nil {
+		log.Printf("I wasn't able to write down who owes what for %v:\n\t%v\n", s.Key, err)
+	}
+}
+
+// forgiveDebts takes back what everyone owed for a delivery that was canceled, and
+// splits any cancellation fee between them instead
+func (g *Garcon) forgiveDebts(s *Session, fee int) {
+	payer := g.Patrons[s.InterlocutorID].Name
+	people := s.Order.People()
+	fees := splitAmount(fee, make([]int, len(people)))
+
+	entries := []LedgerEntry{}
+	for _, e := range g.Ledger.entriesFor(s.Delivery.ID) {
+		entries = append(entries, LedgerEntry{
+			Time:        g.now(),
+			Channel:     e.Channel,
+			From:        e.To,
+			To:          e.From,
+			Amount:      e.Amount,
+			Description: fmt.Sprintf("the order from %v was canceled", s.ActualRestaurant.Name),
+			Reference:   e.Reference,
+		})
+	}
+	for i, person := range people {
+		if person == payer || fees[i] == 0 {
+			continue
+		}
+		entries = append(entries, LedgerEntry{
+			Time:        g.now(),
+			Channel:     s.Channel,
+			From:        person,
+			To:          payer,
+			Amount:      fees[i],
+			Description: fmt.Sprintf("their share of the fee for canceling the order from %v", s.ActualRestaurant.Name),
+			Reference:   s.Delivery.ID,
+		})
+	}
+	if err := g.Ledger.Add(entries...); err != nil {
+		log.Printf("I wasn't able to write down who owes what for %v:\n\t%v\n", s.Key, err)
+	}
+}
+
+func (l *Ledger) entriesFor(reference string) []LedgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []LedgerEntry{}
+	for _, e := range l.Entries {
+		if e.Reference == reference {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ledgerRequested returns whether a message is asking about, or adding to, the ledger,
+// which people can do whatever their order's up to
+func (g *Garcon) ledgerRequested(m Message) bool {
+	return g.MessageAddressesGarcon(m) && (stringFitsPattern(whatDoIOwePattern, m.Text) || stringFitsPattern(paidBackPattern, m.Text))
+}
+
+func (g *Garcon) ledgerResponse(s *Session, m Message) []OutgoingMessage {
+	if stringFitsPattern(paidBackPattern, m.Text) {
+		return g.paidBack(s, m)
+	}
+	return g.whatIOweResponse(s, m)
+}
+
+// describeDebts says who owes what from the given person's point of view
+func (g *Garcon) describeDebts(person string, debts []Debt) string {
+	lines := []string{}
+	for _, d := range debts {
+		amount := describeMoney(d.Amount, g.Limits.Currency)
+		if d.From == person {
+			lines = append(lines, fmt.Sprintf("You owe @%v %v.", d.To, amount))
+		} else {
+			lines = append(lines, fmt.Sprintf("@%v owes you %v.", d.From, amount))
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+func (g *Garcon) whatIOweResponse(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	debts := g.Ledger.DebtsOf(name)
+	t := fmt.Sprintf("@%v, you're square with everyone!", name)
+	if len(debts) > 0 {
+		t = fmt.Sprintf("@%v: %v", name, g.describeDebts(name, debts))
+	}
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+// paidBack notes that someone paid back the person telling Garcon about it. Only the
+// person who got paid can say so, so nobody can write off their own debts.
+func (g *Garcon) paidBack(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	match, _ := findElementsInString(paidBackPattern, []string{"mention", "name", "amount"}, m.Text)
+	payer := match["name"]
+	known := false
+	if p, ok := g.Patrons[match["mention"]]; ok {
+		payer, known = p.Name, true
+	}
+	for _, p := range g.Patrons {
+		if !known && strings.ToLower(p.Name) == strings.ToLower(payer) {
+			// the ledger goes by the name Slack has for them, however it was typed
+			payer, known = p.Name, true
+		}
+	}
+	if !known || payer == name {
+		t := fmt.Sprintf("I'm sorry, @%v, I don't know who \"%v\" is.", name, payer)
+		if known {
+			t = fmt.Sprintf("I'm sorry, @%v, you can't pay yourself back.", name)
+		}
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+	amount, err := parseMoney(match["amount"])
+	if err != nil || amount == 0 {
+		return g.genericHelpResponse(s, m)
+	}
+
+	err = g.Ledger.Add(LedgerEntry{
+		Time:        g.now(),
+		Channel:     m.Channel,
+		From:        name,
+		To:          payer,
+		Amount:      amount,
+		Description: fmt.Sprintf("%v paid %v back", payer, name),
+	})
+	if err != nil {
+		log.Printf("I wasn't able to write down that %v paid %v back:\n\t%v\n", payer, name, err)
+	}
+
+	t := fmt.Sprintf("Okay @%v, I've noted that @%v paid you back %v.", name, payer, describeMoney(amount, g.Limits.Currency))
+	between := []Debt{}
+	for _, d := range g.Ledger.DebtsOf(name) {
+		if d.From == payer || d.To == payer {
+			between = append(between, d)
+		}
+	}
+	if len(between) == 0 {
+		t += " You two are square now."
+	} else {
+		t = fmt.Sprintf("%v %v", t, g.describeDebts(name, between))
+	}
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+// settleUp posts a summary of who still owes whom once a month, in every channel that
+// ordered since the last one
+func (g *Garcon) settleUp(now time.Time) []OutgoingMessage {
+	month := spendingMonth(now)
+	g.Ledger.mu.Lock()
+	last := g.Ledger.SettledUp
+	if last == month {
+		g.Ledger.mu.Unlock()
+		return nil
+	}
+	g.Ledger.SettledUp = month
+	if err := g.Ledger.save(); err != nil {
+		log.Printf("I wasn't able to save the ledger:\n\t%v\n", err)
+	}
+	// the people who've been in on orders in each channel since the last settle-up
+	people := map[string]map[string]bool{}
+	for _, e := range g.Ledger.Entries {
+		if len(last) > 0 && spendingMonth(e.Time) >= last {
+			if people[e.Channel] == nil {
+				people[e.Channel] = map[string]bool{}
+			}
+			people[e.Channel][e.From] = true
+			people[e.Channel][e.To] = true
+		}
+	}
+	g.Ledger.mu.Unlock()
+
+	channels := []string{}
+	for channel := range people {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	debts := g.Ledger.Debts()
+	responses := []OutgoingMessage{}
+	for _, channel := range channels {
+		lines := []string{}
+		for _, d := range debts {
+			if people[channel][d.From] || people[channel][d.To] {
+				lines = append(lines, fmt.Sprintf("@%v owes @%v %v", d.From, d.To, describeMoney(d.Amount, g.Limits.Currency)))
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		t := fmt.Sprintf("It's a new month, so time to settle up! Here's who still owes whom:\n • %v\nSay \"@garcon, <name> paid me back <amount>\" when you've been paid.", strings.Join(lines, "\n • "))
+		responses = append(responses, OutgoingMessage{Channel: channel, Text: t})
+	}
+	return responses
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func returnGarconWithSplitOrder() (*Garcon, *Session, Message) {
+	g, s, m, _ := returnGarconAwaitingApproval(600)
+	g.Patrons["G4RYG4RY1"] = Patron{ID: "G4RYG4RY1", Name: "gary"}
+	s.Order = Order{
+		"brainfart": []LineItem{LineItem{Quantity: 1, Name: "peach melba", Price: 500}},
+		"gary":      []LineItem{LineItem{Quantity: 1, Name: "banana split", Price: 1500}},
+	}
+	return g, s, m
+}
+
+func TestGarconKeepsTrackOfWhoOwesWhom(t *testing.T) {
+	g, _, m := returnGarconWithSplitOrder()
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	g.RespondToMessage(m)
+
+	m.Text = "<@G4RC0NB0T>, what do I owe?"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "@brainfart: @gary owes you 18.00 USD.", messages[0].Text)
+
+	m.User = "G4RYG4RY1"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "@gary: You owe @brainfart 18.00 USD.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T>, stranger paid me back $5"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @gary, I don't know who \"stranger\" is.", messages[0].Text)
+
+	m.User = "L0LWTFBBQ"
+	m.Text = "<@G4RC0NB0T>, <@G4RYG4RY1> paid me back $12.50"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've noted that @gary paid you back 12.50 USD. @gary owes you 5.50 USD.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T>, Gary paid me back 5.50"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've noted that @gary paid you back 5.50 USD. You two are square now.", messages[0].Text)
+	assert.Empty(t, g.Ledger.Debts())
+
+	m.Text = "<@G4RC0NB0T>, BrainFart paid me back 5"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, you can't pay yourself back.", messages[0].Text)
+}
+
+func TestGarconForgivesDebtsForCanceledDeliveries(t *testing.T) {
+	g, s, m := returnGarconWithSplitOrder()
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+
+	provider := &scriptedDeliveryProvider{newFakeDeliveryProvider(), "pending"}
+	g.DeliveryProvider = provider
+	m.Text = "<@G4RC0NB0T>, cancel the delivery"
+	g.RespondToMessage(m)
+	assert.Equal(t, "uninitiated", s.Stage)
+	assert.Equal(t, []Debt{Debt{From: "gary", To: "brainfart", Amount: 125}}, g.Ledger.Debts(), "only the cancellation fee should be owed")
+}
+
+func TestGarconPostsAMonthlySettleUp(t *testing.T) {
+	g, _, _ := returnGarconAndEmptyMessage()
+	july := time.Date(2016, 7, 4, 11, 30, 0, 0, time.UTC)
+	assert.Empty(t, g.Tick(july), "there's nothing to settle the first time around")
+
+	g.Ledger.Add(
+		LedgerEntry{Time: july, Channel: "food", From: "gary", To: "brainfart", Amount: 1800},
+		LedgerEntry{Time: july, Channel: "food", From: "alice", To: "brainfart", Amount: 700},
+		LedgerEntry{Time: july, Channel: "food", From: "brainfart", To: "alice", Amount: 700},
+	)
+	assert.Empty(t, g.Tick(july.Add(time.Hour)))
+
+	messages := g.Tick(july.AddDate(0, 1, 0))
+	if assert.Equal(t, 1, len(messages)) {
+		assert.Equal(t, "food", messages[0].Channel)
+		assert.Equal(t, "It's a new month, so time to settle up! Here's who still owes whom:\n • @gary owes @brainfart 18.00 USD\nSay \"@garcon, <name> paid me back <amount>\" when you've been paid.", messages[0].Text)
+	}
+	assert.Empty(t, g.Tick(july.AddDate(0, 1, 1)), "each month should only be settled once")
+}
+
+func TestLedgerIsSaved(t *testing.T) {
+	dir, err := ioutil.TempDir("", "garcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "records", "ledger.json")
+
+	l, err := NewLedger(path)
+	assert.Nil(t, err)
+	assert.Nil(t, l.Add(LedgerEntry{From: "gary", To: "brainfart", Amount: 1800}))
+
+	l, err = NewLedger(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []Debt{Debt{From: "gary", To: "brainfart", Amount: 1800}}, l.Debts())
+}
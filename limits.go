@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const overrideLimitsPattern = "^" + atGarconPattern + "(please )?(override|ignore) the (spending )?limits?"
+
+// SpendingLimits are caps on what orders can cost, in the smallest unit of Currency.
+// A limit of zero means there isn't one.
+type SpendingLimits struct {
+	PerOrder      int
+	PerPerson     int
+	MonthlyBudget int
+	Currency      string
+}
+
+// Spending keeps track of how much the team's spent each month, so orders can be held
+// to a monthly budget
+type Spending struct {
+	// Path is where spending is saved, if anywhere
+	Path string
+
+	mu     sync.Mutex
+	Months map[string]int
+}
+
+// NewSpending constructs a Spending that's saved to the given path, picking up whatever
+// was saved there before. An empty path keeps spending in memory only.
+func NewSpending(path string) (*Spending, error) {
+	sp := &Spending{Path: path, Months: make(map[string]int)}
+	if len(path) == 0 {
+		return sp, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("unable to create directory for %v: %v", path, err)
+	}
+	if err := loadJSON(path, &sp.Months); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+func spendingMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// SpentIn returns how much was spent in the month the given time falls in
+func (sp *Spending) SpentIn(t time.Time) int {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.Months[spendingMonth(t)]
+}
+
+// Add puts an amount towards the month the given time falls in. Refunds can be added
+// as negative amounts.
+func (sp *Spending) Add(t time.Time, amount int) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.Months[spendingMonth(t)] += amount
+	if len(sp.Path) == 0 {
+		return nil
+	}
+	return saveJSON(sp.Path, sp.Months)
+}
+
+// orderCost is what the session's order costs, as far as anyone knows, including tax,
+// tip, and delivery if it's been quoted
+func (g *Garcon) orderCost(s *Session) int {
+	total := g.orderTotal(s)
+	cost := total.Subtotal + total.Tax + total.Tip
+	if s.Quote != nil {
+		cost += s.Quote.Fee
+	}
+	return cost
+}
+
+// spend records money going out on the session's order, or coming back in
+func (g *Garcon) spend(s *Session, amount int) {
+	if err := g.Spending.Add(g.now(), amount); err != nil {
+		log.Printf("I wasn't able to record %v of spending for %v:\n\t%v\n", describeMoney(amount, g.Limits.Currency), s.Key, err)
+	}
+}
+
+// limitsExceeded describes every spending limit the session's order is over
+func (g *Garcon) limitsExceeded(s *Session) []string {
+	exceeded := []string{}
+	money := func(amount int) string {
+		return describeMoney(amount, g.Limits.Currency)
+	}
+
+	if g.Limits.PerPerson > 0 {
+		for _, person := range s.Order.People() {
+			if subtotal := s.Order.Subtotal(person); subtotal > g.Limits.PerPerson {
+				exceeded = append(exceeded, fmt.Sprintf("@%v's part comes to %v, over the %v allowance per person", person, money(subtotal), money(g.Limits.PerPerson)))
+			}
+		}
+	}
+	cost := g.orderCost(s)
+	if g.Limits.PerOrder > 0 && cost > g.Limits.PerOrder {
+		exceeded = append(exceeded, fmt.Sprintf("the order comes to %v, over the %v limit per order", money(cost), money(g.Limits.PerOrder)))
+	}
+	if g.Limits.MonthlyBudget > 0 {
+		if spent := g.Spending.SpentIn(g.now()) + cost; spent > g.Limits.MonthlyBudget {
+			exceeded = append(exceeded, fmt.Sprintf("it would bring this month's spending to %v, over the %v monthly budget", money(spent), money(g.Limits.MonthlyBudget)))
+		}
+	}
+	return exceeded
+}
+
+// hasAdmins returns whether anyone could possibly override a spending limit
+func (g *Garcon) hasAdmins() bool {
+	if g.DefaultRole >= AdminRole {
+		return true
+	}
+	for _, role := range g.Roles {
+		if role >= AdminRole {
+			return true
+		}
+	}
+	return false
+}
+
+// overLimitResponse holds an order back in confirmation because it's over a spending limit
+func (g *Garcon) overLimitResponse(s *Session, m Message, exceeded []string) []OutgoingMessage {
+	log.Printf("The order for %v is over its spending limits:\n\t%v\n", s.Key, strings.Join(exceeded, "\n\t"))
+	s.Record(g.now(), "the order was held back for being over its spending limits")
+	s.Quote = nil
+	s.Stage = "confirmation"
+
+	t := fmt.Sprintf("I'm sorry, I can't send this order off, because it's over the spending limits:\n • %v\n", strings.Join(exceeded, "\n • "))
+	if g.hasAdmins() {
+		t += "Say \"no\" to start the order over, or an admin can say \"@garcon, override the limits\" to send it anyway."
+	} else {
+		t += "Say \"no\" to start the order over."
+	}
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
+
+// overrideLimits lets an order go through in spite of the spending limits
+func (g *Garcon) overrideLimits(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	log.Printf("@%v overrode the spending limits for %v.", name, s.Key)
+	s.Record(g.now(), "%v overrode the spending limits", name)
+	s.LimitsOverridden = true
+	return g.quoteOrder(s, m)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGarconHoldsBackOrdersOverTheLimits(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(599)
+	g.Limits = SpendingLimits{PerOrder: 2000, PerPerson: 1000, Currency: "usd"}
+	s.Order = Order{"brainfart": []LineItem{LineItem{Quantity: 2, Name: "peach melba", Price: 750}}}
+
+	m.Text = "yes"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, I can't send this order off, because it's over the spending limits:\n • @brainfart's part comes to 15.00 USD, over the 10.00 USD allowance per person\n • the order comes to 20.99 USD, over the 20.00 USD limit per order\nSay \"no\" to start the order over.", messages[0].Text)
+	assert.Equal(t, "confirmation", s.Stage)
+
+	s.Order["brainfart"][0].Quantity = 1
+	g.RespondToMessage(m)
+	assert.Equal(t, "approval", s.Stage, "an order within the limits should go through")
+}
+
+func TestGarconLetsAdminsOverrideTheLimits(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(599)
+	g.Limits = SpendingLimits{MonthlyBudget: 10000, Currency: "usd"}
+	g.Spending.Add(g.now(), 9500)
+	g.Patrons["B0SSB0SS1"] = Patron{ID: "B0SSB0SS1", Name: "boss"}
+	g.Roles["boss"] = AdminRole
+
+	m.Text = "yes"
+	messages := g.RespondToMessage(m)
+	assert.Contains(t, messages[0].Text, "it would bring this month's spending to 100.99 USD, over the 100.00 USD monthly budget")
+	assert.Contains(t, messages[0].Text, "an admin can say \"@garcon, override the limits\"")
+
+	m.Text = "<@G4RC0NB0T>, override the limits"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, you're not allowed to do that. Only admins can.", messages[0].Text)
+	assert.Equal(t, "confirmation", s.Stage)
+
+	m.User = "B0SSB0SS1"
+	g.RespondToMessage(m)
+	assert.Equal(t, "approval", s.Stage)
+
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+	assert.Equal(t, 9500+599, g.Spending.SpentIn(g.now()), "the delivery fee should count towards the month's spending")
+}
+
+func TestGarconForgetsOverridesWhenTheOrderChanges(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(599)
+	g.Limits = SpendingLimits{PerOrder: 2000, Currency: "usd"}
+	s.Order = Order{"brainfart": []LineItem{LineItem{Quantity: 2, Name: "peach melba", Price: 750}}}
+	g.Patrons["B0SSB0SS1"] = Patron{ID: "B0SSB0SS1", Name: "boss"}
+	g.Roles["boss"] = AdminRole
+
+	m.User = "B0SSB0SS1"
+	m.Text = "<@G4RC0NB0T>, override the limits"
+	g.RespondToMessage(m)
+	assert.Equal(t, "approval", s.Stage)
+
+	m.User = s.InterlocutorID
+	m.Text = "no"
+	g.RespondToMessage(m)
+	assert.Equal(t, "ordering", s.Stage)
+
+	m.Text = "<@G4RC0NB0T> I'll have a banana split for $15"
+	g.RespondToMessage(m)
+	m.Text = "I think we're ready"
+	g.RespondToMessage(m)
+	m.Text = "yes"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, I can't send this order off, because it's over the spending limits:\n • the order comes to 35.99 USD, over the 20.00 USD limit per order\nSay \"no\" to start the order over, or an admin can say \"@garcon, override the limits\" to send it anyway.", messages[0].Text)
+	assert.Equal(t, "confirmation", s.Stage)
+}
+
+func TestSpendingIsSavedByMonth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "garcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "records", "spending.json")
+
+	sp, err := NewSpending(path)
+	assert.Nil(t, err)
+	july := time.Date(2016, 7, 4, 11, 30, 0, 0, time.UTC)
+	assert.Nil(t, sp.Add(july, 1200))
+	assert.Nil(t, sp.Add(july.AddDate(0, 0, 10), 800))
+	assert.Nil(t, sp.Add(july.AddDate(0, 1, 0), 500))
+
+	sp, err = NewSpending(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2000, sp.SpentIn(july))
+	assert.Equal(t, 500, sp.SpentIn(july.AddDate(0, 1, 0)))
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minMenuMatch is how well an ordered item has to match something on the menu for
+	// Garcon to consider it, from 0 to 1
+	minMenuMatch = 0.5
+	// clearMenuMatch is how much better than everything else the best match has to be
+	// for Garcon to go with it without asking
+	clearMenuMatch = 0.2
+	// maxMenuOptions is how many items Garcon will ask someone to choose between
+	maxMenuOptions = 5
+)
+
+// MenuItem is something a restaurant sells
+type MenuItem struct {
+	Name string `json:"name"`
+	// Price is in the smallest unit of currency, or zero if the menu doesn't say
+	Price int `json:"price,omitempty"`
+}
+
+// Menu is everything a restaurant sells
+type Menu struct {
+	PlaceID string     `json:"place_id"`
+	Items   []MenuItem `json:"items"`
+}
+
+// Clarification is an item someone ordered that could have been any of several things
+// on the menu, waiting on them to say which
+type Clarification struct {
+	Item    LineItem   `json:"item"`
+	Options []MenuItem `json:"options"`
+	// Replacing is the name of the item being changed, if the item is a change
+	Replacing string `json:"replacing,omitempty"`
+}
+
+// MenuLibrary finds menus in a directory, where each restaurant's menu is a JSON or
+// CSV file named after its place ID. Menus are read when they're needed, so new ones
+// can be dropped in without restarting Garcon.
+type MenuLibrary struct {
+	Dir string
+}
+
+// menuFileItem is a menu item as it's written in a JSON menu file, with its price in
+// whole units of currency like a person would write it
+type menuFileItem struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+// MenuFor returns the menu of the restaurant with the given place ID, or nil if
+// there isn't one
+func (ml *MenuLibrary) MenuFor(placeID string) (*Menu, error) {
+	if len(placeID) == 0 {
+		return nil, nil
+	}
+	for _, ext := range []string{".json", ".csv"} {
+		f, err := os.Open(filepath.Join(ml.Dir, placeID+ext))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		menu := &Menu{PlaceID: placeID}
+		if ext == ".json" {
+			menu.Items, err = readJSONMenu(f)
+		} else {
+			menu.Items, err = readCSVMenu(f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the menu in %v: %v", f.Name(), err)
+		}
+		return menu, nil
+	}
+	return nil, nil
+}
+
+// readJSONMenu reads a list of items like {"name": "Tuna Melt", "price": 8.50}
+func readJSONMenu(r io.Reader) ([]MenuItem, error) {
+	fileItems := []menuFileItem{}
+	if err := json.NewDecoder(r).Decode(&fileItems); err != nil {
+		return nil, err
+	}
+	items := []MenuItem{}
+	for _, fi := range fileItems {
+		if len(strings.TrimSpace(fi.Name)) == 0 {
+			return nil, fmt.Errorf("every item needs a name")
+		}
+		items = append(items, MenuItem{Name: strings.TrimSpace(fi.Name), Price: cents(fi.Price)})
+	}
+	return items, nil
+}
+
+// readCSVMenu reads rows of name and price, like "Tuna Melt,8.50". A header row is
+// skipped, and the price can be left off.
+func readCSVMenu(r io.Reader) ([]MenuItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []MenuItem{}
+	for i, row := range rows {
+		name := strings.TrimSpace(row[0])
+		if i == 0 && strings.ToLower(name) == "name" {
+			continue
+		}
+		if len(name) == 0 {
+			continue
+		}
+		item := MenuItem{Name: name}
+		if len(row) > 1 && len(strings.TrimSpace(row[1])) > 0 {
+			if item.Price, err = parseMoney(row[1]); err != nil {
+				return nil, fmt.Errorf("line %v: %v", i+1, err)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// menuWords splits a name into lowercase words, ignoring punctuation
+func menuWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// editDistance returns how many single letter edits it takes to turn a into b, where
+// swapping two letters next to each other counts as one edit
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	rows := make([][]int, len(ar)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(br)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		for j := 1; j <= len(br); j++ {
+			d := rows[i-1][j-1]
+			if ar[i-1] != br[j-1] {
+				d++
+			}
+			if rows[i-1][j]+1 < d {
+				d = rows[i-1][j] + 1
+			}
+			if rows[i][j-1]+1 < d {
+				d = rows[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] && rows[i-2][j-2]+1 < d {
+				d = rows[i-2][j-2] + 1
+			}
+			rows[i][j] = d
+		}
+	}
+	return rows[len(ar)][len(br)]
+}
+
+// wordsAlike returns whether two words are close enough to be the same word misspelled,
+// or one's just the plural of the other
+func wordsAlike(a, b string) bool {
+	longest := len([]rune(a))
+	if n := len([]rune(b)); n > longest {
+		longest = n
+	}
+	return editDistance(a, b) <= longest/4
+}
+
+// menuMatch scores how well something someone ordered matches an item's name, from 0
+// for nothing in common to 1 for every word matching
+func menuMatch(ordered, name string) float64 {
+	ow, nw := menuWords(ordered), menuWords(name)
+	if len(ow) == 0 || len(nw) == 0 {
+		return 0
+	}
+	matched := 0
+	for _, o := range ow {
+		for _, n := range nw {
+			if wordsAlike(o, n) {
+				matched++
+				break
+			}
+		}
+	}
+	return float64(matched) / float64(len(ow)) * float64(matched) / float64(len(nw))
+}
+
+// Match returns the items on the menu that best match something someone ordered.
+// There's only one if it's a clear winner, several if it could've been any of them,
+// and none if nothing's close.
+func (menu *Menu) Match(ordered string) []MenuItem {
+	type scored struct {
+		item  MenuItem
+		score float64
+	}
+	candidates := []scored{}
+	for _, item := range menu.Items {
+		if score := menuMatch(ordered, item.Name); score >= minMenuMatch {
+			candidates = append(candidates, scored{item, score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	matches := []MenuItem{}
+	for i, c := range candidates {
+		if i == maxMenuOptions || (i > 0 && candidates[0].score-c.score >= clearMenuMatch) {
+			break
+		}
+		if i > 0 && candidates[0].score == 1 {
+			// an exact match beats anything that's merely close
+			break
+		}
+		matches = append(matches, c.item)
+	}
+	return matches
+}
+
+// fromMenu makes an ordered item into the menu item it was matched to
+func fromMenu(li LineItem, item MenuItem) LineItem {
+	li.Name = item.Name
+	li.Price = item.Price
+	return li
+}
+
+func describeMenuItem(item MenuItem, currency string) string {
+	if item.Price == 0 {
+		return item.Name
+	}
+	return fmt.Sprintf("%v (%v)", item.Name, describeMoney(item.Price, currency))
+}
+
+// checkAgainstMenu matches an ordered item to the session's menu. If it can't tell
+// which menu item was meant, it asks, and if nothing matches, it says so. Either way,
+// ok is false and the responses are what to say.
+func (g *Garcon) checkAgainstMenu(s *Session, m Message, li LineItem, replacing string) (matched LineItem, responses []OutgoingMessage, ok bool) {
+	name := g.Patrons[m.User].Name
+	matches := s.Menu.Match(li.Name)
+	switch len(matches) {
+	case 0:
+		t := fmt.Sprintf("I'm sorry, @%v, I don't see anything like \"%v\" on the menu at %v.", name, li.Name, s.ActualRestaurant.Name)
+		return li, []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}, false
+	case 1:
+		return fromMenu(li, matches[0]), nil, true
+	}
+
+	if s.Clarifications == nil {
+		s.Clarifications = make(map[string]Clarification)
+	}
+	s.Clarifications[name] = Clarification{Item: li, Options: matches, Replacing: replacing}
+
+	lines := []string{fmt.Sprintf("@%v, there are a few things like \"%v\" on the menu. Which did you mean?", name, li.Name)}
+	for i, item := range matches {
+		lines = append(lines, fmt.Sprintf("%v. %v", i+1, describeMenuItem(item, g.Limits.Currency)))
+	}
+	lines = append(lines, "Say the number of the one you'd like, or \"none of those\".")
+	return li, []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: strings.Join(lines, "\n")}}, false
+}
+
+// awaitingClarification returns whether a message is someone answering Garcon's
+// question about which menu item they meant
+func (g *Garcon) awaitingClarification(s *Session, m Message) bool {
+	c, ok := s.Clarifications[g.Patrons[m.User].Name]
+	if !ok {
+		return false
+	}
+	choice := numberChosen(m.Text)
+	return (choice >= 0 && choice < len(c.Options)) || noneOfTheseChoices(m)
+}
+
+// clarifyItem settles which menu item someone meant, and puts it on the order
+func (g *Garcon) clarifyItem(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	c := s.Clarifications[name]
+	delete(s.Clarifications, name)
+
+	choice := numberChosen(m.Text)
+	if choice < 0 || choice >= len(c.Options) {
+		t := fmt.Sprintf("Okay @%v, I've left that off your order.", name)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+
+	li := fromMenu(c.Item, c.Options[choice])
+	if len(c.Replacing) > 0 {
+		return g.replaceLineItem(s, m, c.Replacing, li)
+	}
+	return g.addLineItem(s, m, li)
+}
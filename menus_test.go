@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func returnTestMenu() *Menu {
+	return &Menu{PlaceID: "gary", Items: []MenuItem{
+		MenuItem{Name: "Tuna Melt", Price: 850},
+		MenuItem{Name: "Patty Melt", Price: 900},
+		MenuItem{Name: "Bean Burrito", Price: 700},
+		MenuItem{Name: "Taco", Price: 300},
+		MenuItem{Name: "Horchata", Price: 250},
+	}}
+}
+
+func TestMenuLibraryReadsJSONAndCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "menus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "gary.json"), []byte(`[{"name": "Tuna Melt", "price": 8.50}, {"name": "Horchata", "price": 2.5}]`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "chilis.csv"), []byte("name,price\nChips & Queso,6.99\n\"Ribs, Half Rack\",15\nWater\n"), 0644)
+
+	ml := &MenuLibrary{Dir: dir}
+	menu, err := ml.MenuFor("gary")
+	assert.Nil(t, err)
+	assert.Equal(t, &Menu{PlaceID: "gary", Items: []MenuItem{MenuItem{Name: "Tuna Melt", Price: 850}, MenuItem{Name: "Horchata", Price: 250}}}, menu)
+
+	menu, err = ml.MenuFor("chilis")
+	assert.Nil(t, err)
+	assert.Equal(t, []MenuItem{MenuItem{Name: "Chips & Queso", Price: 699}, MenuItem{Name: "Ribs, Half Rack", Price: 1500}, MenuItem{Name: "Water"}}, menu.Items)
+
+	menu, err = ml.MenuFor("applebees")
+	assert.Nil(t, err)
+	assert.Nil(t, menu)
+}
+
+func TestMenuMatch(t *testing.T) {
+	menu := returnTestMenu()
+	expectations := map[string][]string{
+		"tuna melt":    []string{"Tuna Melt"},
+		"tuna mlet":    []string{"Tuna Melt"},
+		"tacos":        []string{"Taco"},
+		"burrito":      []string{"Bean Burrito"},
+		"melt":         []string{"Tuna Melt", "Patty Melt"},
+		"the usual":    []string{},
+		"orchata":      []string{"Horchata"},
+		"patty":        []string{"Patty Melt"},
+		"bean burrito": []string{"Bean Burrito"},
+	}
+	for ordered, expected := range expectations {
+		names := []string{}
+		for _, item := range menu.Match(ordered) {
+			names = append(names, item.Name)
+		}
+		assert.Equal(t, expected, names, ordered)
+	}
+}
+
+func TestGarconChecksOrdersAgainstTheMenu(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+	s.Menu = returnTestMenu()
+
+	m.Text = "<@G4RC0NB0T> I'll have a surprise"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, I don't see anything like \"surprise\" on the menu at Gary's Racoon Hut.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T> I'll have 2 tacos (no onions)"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added 2x Taco (no onions) to your order.", messages[0].Text)
+	assert.Equal(t, 300, s.Order["brainfart"][0].Price)
+
+	m.Text = "<@G4RC0NB0T> I'd like a melt"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "@brainfart, there are a few things like \"melt\" on the menu. Which did you mean?\n1. Tuna Melt (8.50 USD)\n2. Patty Melt (9.00 USD)\nSay the number of the one you'd like, or \"none of those\".", messages[0].Text)
+
+	m.Text = "2"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've added 1x Patty Melt to your order.", messages[0].Text)
+	assert.Empty(t, s.Clarifications)
+
+	m.Text = "<@G4RC0NB0T> change my taco to a melt"
+	g.RespondToMessage(m)
+	m.Text = "the first one"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay @brainfart, I've changed 2x Taco (no onions) to 1x Tuna Melt.", messages[0].Text)
+	assert.Equal(t, "1x Tuna Melt, 1x Patty Melt", describeItems(s.Order["brainfart"]))
+}
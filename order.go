@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pricedItemPattern picks a price off the end of an item, like "a burrito for $8.50"
+const pricedItemPattern = "^(?P<item>.+?),?\\s+(for |at |@ ?)?\\$(?P<price>[0-9]+(\\.[0-9]{1,2})?)$"
+
+const lineItemPattern = "^((?P<quantity>[0-9]+|a|an|one|two|three|four|five|six|seven|eight|nine|ten)( ?x)? )?(the |some )?(?P<name>[^(,]+?)\\s*(\\((?P<parenthetical>[^)]*)\\)|, ?(?P<trailing>.*))?$"
+
+var quantityWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+}
+
+// LineItem is a single thing someone ordered
+type LineItem struct {
+	Quantity int    `json:"quantity"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes,omitempty"`
+	// Price is what one of the item costs, in the smallest unit of currency, or zero
+	// if nobody's said
+	Price int `json:"price,omitempty"`
+}
+
+// Cost is what all of the item costs, as far as anyone knows
+func (li LineItem) Cost() int {
+	return li.Quantity * li.Price
+}
+
+// String describes the item the way a courier would want to read it
+func (li LineItem) String() string {
+	s := fmt.Sprintf("%vx %v", li.Quantity, li.Name)
+	if len(li.Notes) > 0 {
+		s = fmt.Sprintf("%v (%v)", s, li.Notes)
+	}
+	return s
+}
+
+// parseLineItem turns something like "2 tacos (no onions)" or "the tuna melt, no pickles"
+// into a LineItem. Anything without a quantity is assumed to be just one, and a price
+// can be tacked on the end, like "a burrito for $8.50".
+func parseLineItem(text string) (LineItem, error) {
+	text = strings.TrimSpace(text)
+	price := 0
+	if priced, err := findElementsInString(pricedItemPattern, []string{"item", "price"}, text); err == nil {
+		if price, err = parseMoney(priced["price"]); err != nil {
+			return LineItem{}, err
+		}
+		text = priced["item"]
+	}
+	match, err := findElementsInString(lineItemPattern, []string{"quantity", "name", "parenthetical", "trailing"}, text)
+	if err != nil || len(strings.TrimSpace(match["name"])) == 0 {
+		return LineItem{}, fmt.Errorf("I couldn't make sense of \"%v\" as an order", text)
+	}
+
+	li := LineItem{Quantity: 1, Name: strings.TrimSpace(match["name"])}
+	if q := strings.ToLower(match["quantity"]); len(q) > 0 {
+		if n, err := strconv.Atoi(q); err == nil {
+			li.Quantity = n
+		} else {
+			li.Quantity = quantityWords[q]
+		}
+	}
+	if li.Quantity < 1 {
+		return LineItem{}, fmt.Errorf("I can't order %v of something", li.Quantity)
+	}
+	li.Notes = strings.TrimSpace(match["parenthetical"] + match["trailing"])
+	li.Price = price
+	return li, nil
+}
+
+// Order is everything a group wants, keyed by the name of who wants it
+type Order map[string][]LineItem
+
+// Add puts an item on someone's part of the order. Asking for more of something
+// they've already ordered just bumps up the quantity.
+func (o Order) Add(person string, item LineItem) {
+	for i, existing := range o[person] {
+		if strings.ToLower(existing.Name) == strings.ToLower(item.Name) && existing.Notes == item.Notes {
+			o[person][i].Quantity += item.Quantity
+			return
+		}
+	}
+	o[person] = append(o[person], item)
+}
+
+// find returns the index of the person's item best matching the given name, or -1 if
+// nothing they ordered matches it
+func (o Order) find(person, name string) int {
+	name = strings.ToLower(name)
+	for i, item := range o[person] {
+		if strings.ToLower(item.Name) == name {
+			return i
+		}
+	}
+	for i, item := range o[person] {
+		if strings.Contains(strings.ToLower(item.Name), name) || strings.Contains(name, strings.ToLower(item.Name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Remove takes the item matching the given name off someone's part of the order,
+// returning what was removed
+func (o Order) Remove(person, name string) (LineItem, bool) {
+	i := o.find(person, name)
+	if i < 0 {
+		return LineItem{}, false
+	}
+	removed := o[person][i]
+	o[person] = append(o[person][:i], o[person][i+1:]...)
+	if len(o[person]) == 0 {
+		delete(o, person)
+	}
+	return removed, true
+}
+
+// Replace swaps the item matching the given name for another, returning what was replaced
+func (o Order) Replace(person, name string, item LineItem) (LineItem, bool) {
+	i := o.find(person, name)
+	if i < 0 {
+		return LineItem{}, false
+	}
+	replaced := o[person][i]
+	o[person][i] = item
+	return replaced, true
+}
+
+// Subtotal is what someone's part of the order costs, counting only items with prices
+func (o Order) Subtotal(person string) (subtotal int) {
+	for _, item := range o[person] {
+		subtotal += item.Cost()
+	}
+	return
+}
+
+// Total is what the whole order costs, counting only items with prices
+func (o Order) Total() (total int) {
+	for person := range o {
+		total += o.Subtotal(person)
+	}
+	return
+}
+
+// People returns everyone with something on the order, in alphabetical order
+func (o Order) People() []string {
+	people := []string{}
+	for person, items := range o {
+		if len(items) > 0 {
+			people = append(people, person)
+		}
+	}
+	sort.Strings(people)
+	return people
+}
+
+// describeItems lists a set of items on a single line
+func describeItems(items []LineItem) string {
+	descriptions := []string{}
+	for _, item := range items {
+		descriptions = append(descriptions, item.String())
+	}
+	return strings.Join(descriptions, ", ")
+}
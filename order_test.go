@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLineItem(t *testing.T) {
+	expectations := map[string]LineItem{
+		"a banana":                      LineItem{Quantity: 1, Name: "banana"},
+		"the tuna melt":                 LineItem{Quantity: 1, Name: "tuna melt"},
+		"2 tacos (no onions)":           LineItem{Quantity: 2, Name: "tacos", Notes: "no onions"},
+		"three orders of fries":         LineItem{Quantity: 3, Name: "orders of fries"},
+		"4x queso, extra jalapeños":     LineItem{Quantity: 4, Name: "queso", Notes: "extra jalapeños"},
+		"apple pie":                     LineItem{Quantity: 1, Name: "apple pie"},
+		"an everything bagel (toasted)": LineItem{Quantity: 1, Name: "everything bagel", Notes: "toasted"},
+		"a burrito for $8.50":           LineItem{Quantity: 1, Name: "burrito", Price: 850},
+		"2 tacos (no onions) $3":        LineItem{Quantity: 2, Name: "tacos", Notes: "no onions", Price: 300},
+	}
+
+	for text, expected := range expectations {
+		actual, err := parseLineItem(text)
+		assert.Nil(t, err, text)
+		assert.Equal(t, expected, actual, text)
+	}
+
+	_, err := parseLineItem("0 tacos")
+	assert.NotNil(t, err)
+}
+
+func TestOrderAddsUpRepeatedItems(t *testing.T) {
+	o := make(Order)
+	o.Add("gary", LineItem{Quantity: 1, Name: "taco"})
+	o.Add("gary", LineItem{Quantity: 1, Name: "fries"})
+	o.Add("gary", LineItem{Quantity: 2, Name: "Taco"})
+	o.Add("alice", LineItem{Quantity: 1, Name: "taco", Notes: "no onions"})
+
+	assert.Equal(t, []string{"alice", "gary"}, o.People())
+	assert.Equal(t, "3x taco, 1x fries", describeItems(o["gary"]))
+	assert.Equal(t, "1x taco (no onions)", describeItems(o["alice"]))
+}
+
+func TestGarconTakesSeveralItemsFromOnePerson(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	s.Stage = "ordering"
+	s.RequestedRestaurant = "Gary's Racoon Hut"
+
+	m.Text = "<@G4RC0NB0T> I'll have 2 tacos (no onions)"
+	g.RespondToMessage(m)
+	m.Text = "<@G4RC0NB0T> I'd like a horchata"
+	g.RespondToMessage(m)
+
+	m.Text = "<@G4RC0NB0T> what's our order look like so far?"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Here's what I have for your order from Gary's Racoon Hut:\n```\nBrainfart: 2x tacos (no onions), 1x horchata\n```", messages[0].Text)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PickupProvider is a DeliveryProvider for when someone from the office goes to get
+// the food themselves. It's free, and it only knows what Garcon has told it.
+type PickupProvider struct {
+	mu         sync.Mutex
+	deliveries map[string]*Delivery
+}
+
+// NewPickupProvider constructs a PickupProvider
+func NewPickupProvider() *PickupProvider {
+	return &PickupProvider{deliveries: make(map[string]*Delivery)}
+}
+
+// Quote always comes back free, and never expires
+func (pp *PickupProvider) Quote(from, to *DeliverySpot) (*DeliveryQuote, error) {
+	now := time.Now()
+	return &DeliveryQuote{
+		ID:       fmt.Sprintf("pickup-quote-%v", now.UnixNano()),
+		Currency: "usd",
+		Created:  now,
+	}, nil
+}
+
+// CreateDelivery records that someone needs to go pick the order up
+func (pp *PickupProvider) CreateDelivery(manifest *Manifest, from, to *DeliverySpot, quote *DeliveryQuote) (*Delivery, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	d := &Delivery{
+		ID:        fmt.Sprintf("pickup-%v", time.Now().UnixNano()),
+		Status:    "pickup",
+		Currency:  "usd",
+		Untracked: true,
+	}
+	if quote != nil {
+		d.QuoteID = quote.ID
+	}
+	pp.deliveries[d.ID] = d
+
+	c := *d
+	return &c, nil
+}
+
+// DeliveryStatus returns what the provider knows about a pickup
+func (pp *PickupProvider) DeliveryStatus(id string) (*Delivery, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	d, ok := pp.deliveries[id]
+	if !ok {
+		return nil, fmt.Errorf("I don't know of a pickup with the ID %v", id)
+	}
+	c := *d
+	return &c, nil
+}
+
+// CancelDelivery marks a pickup as no longer needed. There's nobody to call off, so
+// even pickups from before a restart can be canceled.
+func (pp *PickupProvider) CancelDelivery(id string) (*Delivery, error) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	d, ok := pp.deliveries[id]
+	if !ok {
+		d = &Delivery{ID: id, Currency: "usd", Untracked: true}
+		pp.deliveries[id] = d
+	}
+	d.Status = "canceled"
+	c := *d
+	return &c, nil
+}
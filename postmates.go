@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const postmatesBaseURL = "https://api.postmates.com"
+
+// PostmatesProvider is a DeliveryProvider backed by the Postmates API
+type PostmatesProvider struct {
+	CustomerID string
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewPostmatesProvider constructs a PostmatesProvider for the given customer
+func NewPostmatesProvider(customerID, apiKey string) *PostmatesProvider {
+	return &PostmatesProvider{
+		CustomerID: customerID,
+		APIKey:     apiKey,
+		BaseURL:    postmatesBaseURL,
+		HTTPClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+type postmatesError struct {
+	StatusCode int    `json:"-"`
+	Kind       string `json:"kind"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (pe *postmatesError) Error() string {
+	return fmt.Sprintf("postmates responded with %v (%v): %v", pe.StatusCode, pe.Code, pe.Message)
+}
+
+// Temporary returns whether Postmates is having a bad time rather than rejecting the request
+func (pe *postmatesError) Temporary() bool {
+	return pe.StatusCode >= 500 || pe.StatusCode == http.StatusTooManyRequests
+}
+
+type postmatesQuote struct {
+	ID         string    `json:"id"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	Fee        int       `json:"fee"`
+	Currency   string    `json:"currency"`
+	DropoffETA time.Time `json:"dropoff_eta"`
+}
+
+type postmatesDelivery struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	Fee         int       `json:"fee"`
+	Currency    string    `json:"currency"`
+	QuoteID     string    `json:"quote_id"`
+	DropoffETA  time.Time `json:"dropoff_eta"`
+	TrackingURL string    `json:"tracking_url"`
+}
+
+func (pd postmatesDelivery) delivery() *Delivery {
+	return &Delivery{
+		ID:          pd.ID,
+		Status:      pd.Status,
+		Fee:         pd.Fee,
+		Currency:    pd.Currency,
+		QuoteID:     pd.QuoteID,
+		DropoffETA:  pd.DropoffETA,
+		TrackingURL: pd.TrackingURL,
+	}
+}
+
+func (pp *PostmatesProvider) do(method, path string, form url.Values, out interface{}) error {
+	endpoint := fmt.Sprintf("%v/v1/customers/%v%v", pp.BaseURL, pp.CustomerID, path)
+
+	var req *http.Request
+	var err error
+	if form == nil {
+		req, err = http.NewRequest(method, endpoint, nil)
+	} else {
+		req, err = http.NewRequest(method, endpoint, strings.NewReader(form.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(pp.APIKey, "")
+
+	resp, err := pp.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		pe := &postmatesError{}
+		json.NewDecoder(resp.Body).Decode(pe)
+		pe.StatusCode = resp.StatusCode
+		return pe
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Quote asks Postmates how much a delivery would cost
+func (pp *PostmatesProvider) Quote(from, to *DeliverySpot) (*DeliveryQuote, error) {
+	form := url.Values{
+		"pickup_address":  {from.Address},
+		"dropoff_address": {to.Address},
+	}
+
+	pq := postmatesQuote{}
+	if err := pp.do("POST", "/delivery_quotes", form, &pq); err != nil {
+		return nil, err
+	}
+	return &DeliveryQuote{
+		ID:         pq.ID,
+		Fee:        pq.Fee,
+		Currency:   pq.Currency,
+		Created:    pq.Created,
+		Expires:    pq.Expires,
+		DropoffETA: pq.DropoffETA,
+	}, nil
+}
+
+// CreateDelivery asks Postmates to send a courier
+func (pp *PostmatesProvider) CreateDelivery(manifest *Manifest, from, to *DeliverySpot, quote *DeliveryQuote) (*Delivery, error) {
+	form := url.Values{
+		"manifest":             {manifest.Description},
+		"manifest_reference":   {manifest.Reference},
+		"pickup_name":          {from.Name},
+		"pickup_address":       {from.Address},
+		"pickup_phone_number":  {from.PhoneNumber},
+		"dropoff_name":         {to.Name},
+		"dropoff_address":      {to.Address},
+		"dropoff_phone_number": {to.PhoneNumber},
+	}
+	if quote != nil {
+		form.Set("quote_id", quote.ID)
+	}
+
+	pd := postmatesDelivery{}
+	if err := pp.do("POST", "/deliveries", form, &pd); err != nil {
+		return nil, err
+	}
+	return pd.delivery(), nil
+}
+
+// DeliveryStatus asks Postmates how a delivery is going
+func (pp *PostmatesProvider) DeliveryStatus(id string) (*Delivery, error) {
+	pd := postmatesDelivery{}
+	if err := pp.do("GET", "/deliveries/"+url.PathEscape(id), nil, &pd); err != nil {
+		return nil, err
+	}
+	return pd.delivery(), nil
+}
+
+// CancelDelivery asks Postmates to call off a delivery
+func (pp *PostmatesProvider) CancelDelivery(id string) (*Delivery, error) {
+	pd := postmatesDelivery{}
+	if err := pp.do("POST", "/deliveries/"+url.PathEscape(id)+"/cancel", url.Values{}, &pd); err != nil {
+		return nil, err
+	}
+	return pd.delivery(), nil
+}
+
+type postmatesWebhookEvent struct {
+	Kind       string            `json:"kind"`
+	DeliveryID string            `json:"delivery_id"`
+	Status     string            `json:"status"`
+	Data       postmatesDelivery `json:"data"`
+}
+
+// PostmatesWebhookHandler handles the webhooks Postmates sends as deliveries change,
+// passing each changed delivery along to updates
+func PostmatesWebhookHandler(secret string, updates chan<- *Delivery) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "unreadable request", http.StatusBadRequest)
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		signature, err := hex.DecodeString(r.Header.Get("X-Postmates-Signature"))
+		if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+			log.Printf("I received a webhook that I couldn't verify came from Postmates.")
+			http.Error(w, "unverified request", http.StatusUnauthorized)
+			return
+		}
+
+		event := postmatesWebhookEvent{}
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "malformed event", http.StatusBadRequest)
+			return
+		}
+		if event.Kind != "event.delivery_status" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		d := event.Data.delivery()
+		if len(d.ID) == 0 {
+			d.ID = event.DeliveryID
+		}
+		if len(d.Status) == 0 {
+			d.Status = event.Status
+		}
+		updates <- d
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostmatesProviderQuotesAndDelivers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _, _ := r.BasicAuth()
+		assert.Equal(t, "sekrit", key)
+		r.ParseForm()
+
+		switch r.URL.Path {
+		case "/v1/customers/cus_123/delivery_quotes":
+			assert.Equal(t, "123 Fake St", r.PostForm.Get("pickup_address"))
+			fmt.Fprint(w, `{"kind": "delivery_quote", "id": "dqt_1", "fee": 799, "currency": "usd", "expires": "2016-01-01T12:05:00Z", "dropoff_eta": "2016-01-01T12:45:00Z"}`)
+		case "/v1/customers/cus_123/deliveries":
+			assert.Equal(t, "dqt_1", r.PostForm.Get("quote_id"))
+			assert.Equal(t, "Brainfart: a peach melba", r.PostForm.Get("manifest"))
+			fmt.Fprint(w, `{"kind": "delivery", "id": "del_1", "status": "pending", "fee": 799, "currency": "usd", "quote_id": "dqt_1"}`)
+		case "/v1/customers/cus_123/deliveries/del_1/cancel":
+			fmt.Fprint(w, `{"kind": "delivery", "id": "del_1", "status": "canceled"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind": "error", "code": "not_found", "message": "The requested resource does not exist."}`)
+		}
+	}))
+	defer server.Close()
+
+	pp := NewPostmatesProvider("cus_123", "sekrit")
+	pp.BaseURL = server.URL
+	from := NewDeliverySpot("Gary's Racoon Hut", "123 Fake St", "(555) 555-5555")
+	to := NewDeliverySpot("The Office", "1 Main St", "(555) 555-0000")
+
+	quote, err := pp.Quote(from, to)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "dqt_1", quote.ID)
+	assert.Equal(t, 799, quote.Fee)
+	assert.Equal(t, 45, quote.DropoffETA.Minute())
+
+	delivery, err := pp.CreateDelivery(&Manifest{Description: "Brainfart: a peach melba"}, from, to, quote)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "del_1", delivery.ID)
+	assert.Equal(t, "pending", delivery.Status)
+
+	delivery, err = pp.CancelDelivery("del_1")
+	assert.Nil(t, err)
+	assert.Equal(t, "canceled", delivery.Status)
+
+	_, err = pp.DeliveryStatus("del_2")
+	assert.NotNil(t, err)
+}
+
+func TestPostmatesWebhookHandler(t *testing.T) {
+	updates := make(chan *Delivery, 1)
+	handler := PostmatesWebhookHandler("shhh", updates)
+	body := `{"kind": "event.delivery_status", "delivery_id": "del_1", "status": "pickup", "data": {"id": "del_1", "status": "pickup", "fee": 799, "currency": "usd"}}`
+
+	mac := hmac.New(sha256.New, []byte("shhh"))
+	mac.Write([]byte(body))
+	r := httptest.NewRequest("POST", "/postmates/webhook", strings.NewReader(body))
+	r.Header.Set("X-Postmates-Signature", hex.EncodeToString(mac.Sum(nil)))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	d := <-updates
+	assert.Equal(t, "del_1", d.ID)
+	assert.Equal(t, "pickup", d.Status)
+
+	r = httptest.NewRequest("POST", "/postmates/webhook", strings.NewReader(body))
+	r.Header.Set("X-Postmates-Signature", "deadbeef")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
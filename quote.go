@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// notYetAction is the value of the button for sending an order back to be worked on
+const notYetAction = "not_yet"
+
+// restaurantSpot is where the session's order gets picked up from
+func restaurantSpot(s *Session) *DeliverySpot {
+	return NewDeliverySpot(s.ActualRestaurant.Name, s.ActualRestaurant.Address, s.ActualRestaurant.Phone)
+}
+
+// requestQuote asks the delivery provider what the session's order would cost to deliver
+func (g *Garcon) requestQuote(s *Session) error {
+	var quote *DeliveryQuote
+	err := withRetries(g.DeliveryAttempts, g.DeliveryBackoff, g.DeliveryTimeout, func() (err error) {
+		quote, err = g.DeliveryProvider.Quote(restaurantSpot(s), g.OrderDestination)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	s.Quote = quote
+	return nil
+}
+
+// quoteExpired returns whether the session's quote can no longer be used
+func (g *Garcon) quoteExpired(s *Session) bool {
+	return s.Quote == nil || (!s.Quote.Expires.IsZero() && !g.now().Before(s.Quote.Expires))
+}
+
+// describeQuote lists everything worth knowing about a quote before agreeing to it
+func describeQuote(q *DeliveryQuote) string {
+	lines := []string{}
+	if q.Fee == 0 {
+		lines = append(lines, "Delivery fee: free")
+	} else {
+		lines = append(lines, fmt.Sprintf("Delivery fee: %v", describeMoney(q.Fee, q.Currency)))
+	}
+	if !q.DropoffETA.IsZero() {
+		lines = append(lines, fmt.Sprintf("Estimated dropoff: %v", q.DropoffETA.Format("3:04 PM")))
+	}
+	if !q.Expires.IsZero() {
+		lines = append(lines, fmt.Sprintf("Quote expires: %v", q.Expires.Format("3:04 PM")))
+	}
+	return " • " + strings.Join(lines, "\n • ")
+}
+
+func (g *Garcon) approvalRequest(s *Session, m Message, intro string) OutgoingMessage {
+	t := fmt.Sprintf("%v\n%v\n@%v, shall I send it off? Say \"yes\" to approve it, or \"no\" if you'd like to keep working on the order.", intro, describeQuote(s.Quote), g.Patrons[s.InterlocutorID].Name)
+	request := OutgoingMessage{Channel: m.Channel, Text: t}
+	if g.Interactive {
+		request.Buttons = []Choice{
+			Choice{Text: "Send it", Value: confirmAction, Style: "primary"},
+			Choice{Text: "Not yet", Value: notYetAction},
+			Choice{Text: "Cancel", Value: cancelAction, Style: "danger"},
+		}
+	}
+	return request
+}
+
+// quoteOrder gets a delivery quote for a confirmed order, and holds on to it until the
+// interlocutor approves it
+func (g *Garcon) quoteOrder(s *Session, m Message) []OutgoingMessage {
+	if err := g.requestQuote(s); err != nil {
+		return g.deliveryFailedResponse(s, m, "get a delivery quote", err)
+	}
+	s.DeliveryError = ""
+	if exceeded := g.limitsExceeded(s); len(exceeded) > 0 && !s.LimitsOverridden {
+		return g.overLimitResponse(s, m, exceeded)
+	}
+	s.Stage = "approval"
+
+	intro := fmt.Sprintf("Here's what it'll take to get your order from %v delivered:", s.ActualRestaurant.Name)
+	return []OutgoingMessage{g.approvalRequest(s, m, intro)}
+}
+
+// dispatchOrder sends off an order once its quote has been approved. If the quote has
+// expired in the meantime it's renewed first, and if the price changed, it has to be
+// approved all over again.
+func (g *Garcon) dispatchOrder(s *Session, m Message) []OutgoingMessage {
+	if g.quoteExpired(s) {
+		approved := s.Quote
+		if err := g.requestQuote(s); err != nil {
+			return g.deliveryFailedResponse(s, m, "renew the delivery quote", err)
+		}
+		log.Printf("The quote for %v had expired, so I got a new one.", s.Key)
+		if approved == nil || s.Quote.Fee != approved.Fee || s.Quote.Currency != approved.Currency {
+			s.DeliveryError = ""
+			if exceeded := g.limitsExceeded(s); len(exceeded) > 0 && !s.LimitsOverridden {
+				return g.overLimitResponse(s, m, exceeded)
+			}
+			intro := "That quote expired before it was approved, and the new one is different:"
+			return []OutgoingMessage{g.approvalRequest(s, m, intro)}
+		}
+	}
+
+	manifest := &Manifest{Description: g.createOrderString(s), Reference: "Group Order"}
+	var delivery *Delivery
+	err := withRetries(g.DeliveryAttempts, g.DeliveryBackoff, g.DeliveryTimeout, func() (err error) {
+		delivery, err = g.DeliveryProvider.CreateDelivery(manifest, restaurantSpot(s), g.OrderDestination, s.Quote)
+		return
+	})
+	if err != nil {
+		return g.deliveryFailedResponse(s, m, "create the delivery", err)
+	}
+	s.DeliveryError = ""
+	g.spend(s, g.orderCost(s))
+	g.trackDelivery(s, delivery)
+	g.recordDebts(s)
+	g.recordOrder(s)
+	summary := g.orderSummary(s)
+
+	t := "Okay, I'll send this order off!"
+	responses := []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	if shares := g.describeShares(s); len(shares) > 0 {
+		responses = append(responses, OutgoingMessage{Channel: m.Channel, Text: shares})
+	}
+	if len(delivery.TrackingURL) > 0 {
+		t = fmt.Sprintf("You can follow along at %v, or ask me \"where's our food?\"", delivery.TrackingURL)
+		responses = append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+	}
+	if delivery.Untracked {
+		t = "Somebody needs to go pick it up! Say \"@garcon, we picked up the food\" once it's here."
+		responses = append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+	}
+	if len(s.ThreadTimestamp) > 0 {
+		responses = append(responses, OutgoingMessage{Channel: m.Channel, Text: summary, InChannel: true})
+	}
+	return responses
+}
+
+// declineQuote puts the order back in people's hands without sending it
+func (g *Garcon) declineQuote(s *Session, m Message) []OutgoingMessage {
+	s.Quote = nil
+	s.DeliveryError = ""
+	// an override was for the order as it was, not whatever it turns into
+	s.LimitsOverridden = false
+	s.Stage = "ordering"
+
+	t := "Okay, I won't send it yet. The order's still open, so go ahead and make changes, and say \"I think we're ready\" when you're done."
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
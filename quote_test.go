@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pricedDeliveryProvider quotes whatever its next fee is, with quotes that expire
+// after five minutes of the given clock
+type pricedDeliveryProvider struct {
+	fakeDeliveryProvider
+	now  func() time.Time
+	fees []int
+}
+
+func (p *pricedDeliveryProvider) Quote(from, to *DeliverySpot) (*DeliveryQuote, error) {
+	fee := p.fees[0]
+	if len(p.fees) > 1 {
+		p.fees = p.fees[1:]
+	}
+	return &DeliveryQuote{
+		ID:         "quote",
+		Fee:        fee,
+		Currency:   "usd",
+		Expires:    p.now().Add(5 * time.Minute),
+		DropoffETA: p.now().Add(45 * time.Minute),
+	}, nil
+}
+
+func returnGarconAwaitingApproval(fees ...int) (*Garcon, *Session, Message, *time.Time) {
+	g, s, m := returnGarconAndEmptyMessage()
+	now := time.Date(2016, 7, 4, 11, 30, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	g.DeliveryProvider = &pricedDeliveryProvider{newFakeDeliveryProvider(), g.now, fees}
+	s.Stage = "confirmation"
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba"})
+	return g, s, m, &now
+}
+
+func TestGarconAsksForApprovalOfTheQuote(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(599)
+	g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+
+	m.Text = "yes"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Here's what it'll take to get your order from Gary's Racoon Hut delivered:\n • Delivery fee: 5.99 USD\n • Estimated dropoff: 12:15 PM\n • Quote expires: 11:35 AM\n@brainfart, shall I send it off? Say \"yes\" to approve it, or \"no\" if you'd like to keep working on the order.", messages[0].Text)
+	assert.Equal(t, "approval", s.Stage)
+
+	m.User = "SOMEJERK"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @whocares, only @brainfart can do that for this order.", messages[0].Text)
+	assert.Equal(t, "approval", s.Stage)
+
+	m.User = s.InterlocutorID
+	m.Text = "no"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "ordering", s.Stage)
+	assert.Nil(t, s.Quote)
+	assert.Equal(t, 1, len(s.Order["brainfart"]), "declining the quote shouldn't lose the order")
+}
+
+func TestGarconRenewsExpiredQuotes(t *testing.T) {
+	g, _, m, now := returnGarconAwaitingApproval(599)
+	m.Text = "yes"
+	g.RespondToMessage(m)
+
+	*now = now.Add(10 * time.Minute)
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "Okay, I'll send this order off!", messages[0].Text, "a renewed quote at the same price doesn't need approving again")
+}
+
+func TestGarconAsksAgainWhenRenewedQuoteCostsMore(t *testing.T) {
+	g, s, m, now := returnGarconAwaitingApproval(599, 899)
+	m.Text = "yes"
+	g.RespondToMessage(m)
+
+	*now = now.Add(10 * time.Minute)
+	messages := g.RespondToMessage(m)
+	assert.Contains(t, messages[0].Text, "That quote expired before it was approved, and the new one is different:\n • Delivery fee: 8.99 USD")
+	assert.Equal(t, "approval", s.Stage)
+
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Okay, I'll send this order off!", messages[0].Text)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	replChannel     = "repl"
+	replGarconID    = "UGARC0N00"
+	replHelpMessage = "Say things to Garcon as though you were in Slack, mentioning it with @garcon.\n" +
+		"  /as <name>  switch to talking as someone else\n" +
+		"  /who        list everyone you can talk as\n" +
+		"  /click <choice>  click one of the choices garcon offered\n" +
+		"  /garcon <command>  use garcon's slash command, try /garcon help\n" +
+		"  /quit       leave\n"
+)
+
+// ReplTransport is a Transport that lets someone at a terminal pretend to be any
+// number of people talking to Garcon in a single channel
+type ReplTransport struct {
+	in       io.Reader
+	out      io.Writer
+	patrons  []Patron
+	current  Patron
+	messages chan Message
+
+	mu sync.Mutex
+	// thread is the thread Garcon most recently started, which everything typed is
+	// said in, the way people in Slack would carry on an order in its thread
+	thread string
+	sent   int
+}
+
+// NewReplTransport constructs a ReplTransport where the given names are the people
+// available to talk as, and the first one is who's talking to begin with
+func NewReplTransport(in io.Reader, out io.Writer, names []string) *ReplTransport {
+	rt := &ReplTransport{
+		in:       in,
+		out:      out,
+		messages: make(chan Message),
+	}
+	for i, n := range names {
+		// IDs have to look like Slack's for Garcon to recognize mentions
+		rt.patrons = append(rt.patrons, Patron{ID: fmt.Sprintf("U%08d", i+1), Name: n})
+	}
+	rt.current = rt.patrons[0]
+	return rt
+}
+
+// Patrons returns everyone the terminal user can talk as, plus Garcon itself
+func (rt *ReplTransport) Patrons() ([]Patron, error) {
+	return append(rt.patrons, Patron{ID: replGarconID, Name: "garcon"}), nil
+}
+
+// ChannelIDs always returns the one channel the REPL has
+func (rt *ReplTransport) ChannelIDs(names []string) ([]string, error) {
+	return []string{replChannel}, nil
+}
+
+func (rt *ReplTransport) patronNamed(name string) (Patron, bool) {
+	for _, p := range rt.patrons {
+		if strings.ToLower(p.Name) == strings.ToLower(name) {
+			return p, true
+		}
+	}
+	return Patron{}, false
+}
+
+func (rt *ReplTransport) prompt() {
+	fmt.Fprintf(rt.out, "%v> ", rt.current.Name)
+}
+
+// Start begins reading lines from the terminal. Lines beginning with a slash are
+// commands for the REPL itself, everything else is said to Garcon.
+func (rt *ReplTransport) Start() {
+	mention := regexp.MustCompile("(?i)@garcon")
+	fmt.Fprint(rt.out, replHelpMessage)
+
+	go func() {
+		defer close(rt.messages)
+		scanner := bufio.NewScanner(rt.in)
+		for rt.prompt(); scanner.Scan(); rt.prompt() {
+			line := strings.TrimSpace(scanner.Text())
+			fields := strings.Fields(line)
+
+			switch {
+			case len(fields) == 0:
+				continue
+
+			case fields[0] == "/quit":
+				return
+
+			case fields[0] == "/who":
+				for _, p := range rt.patrons {
+					fmt.Fprintf(rt.out, "  %v\n", p.Name)
+				}
+
+			case fields[0] == "/as":
+				if len(fields) != 2 {
+					fmt.Fprintln(rt.out, "usage: /as <name>")
+					continue
+				}
+				p, ok := rt.patronNamed(fields[1])
+				if !ok {
+					fmt.Fprintf(rt.out, "I don't know who %v is, try /who\n", fields[1])
+					continue
+				}
+				rt.current = p
+
+			case fields[0] == "/garcon":
+				command := strings.TrimSpace(strings.TrimPrefix(line, "/garcon"))
+				if len(command) == 0 {
+					command = "help"
+				}
+				rt.messages <- Message{
+					User:            rt.current.ID,
+					Channel:         replChannel,
+					ThreadTimestamp: rt.currentThread(),
+					Command:         command,
+				}
+
+			case fields[0] == "/click":
+				if len(fields) != 2 {
+					fmt.Fprintln(rt.out, "usage: /click <choice>")
+					continue
+				}
+				rt.messages <- Message{
+					User:            rt.current.ID,
+					Channel:         replChannel,
+					ThreadTimestamp: rt.currentThread(),
+					Action:          fields[1],
+				}
+
+			case strings.HasPrefix(fields[0], "/"):
+				fmt.Fprint(rt.out, replHelpMessage)
+
+			default:
+				rt.messages <- Message{
+					User:            rt.current.ID,
+					Channel:         replChannel,
+					ThreadTimestamp: rt.currentThread(),
+					Text:            mention.ReplaceAllString(line, fmt.Sprintf("<@%v>", replGarconID)),
+				}
+			}
+		}
+	}()
+}
+
+// Messages delivers whatever's typed at the terminal, and is closed when the user quits
+func (rt *ReplTransport) Messages() <-chan Message {
+	return rt.messages
+}
+
+func (rt *ReplTransport) currentThread() string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.thread
+}
+
+// Send prints Garcon's message to the terminal, along with any choices it offers
+func (rt *ReplTransport) Send(m OutgoingMessage) (string, error) {
+	rt.mu.Lock()
+	rt.sent++
+	timestamp := fmt.Sprintf("%v.000000", rt.sent)
+	if m.StartsThread {
+		rt.thread = timestamp
+	}
+	rt.mu.Unlock()
+
+	text := m.Text
+	choices := []string{}
+	for _, c := range append(m.Options, m.Buttons...) {
+		choices = append(choices, fmt.Sprintf("[%v] %v", c.Value, c.Text))
+	}
+	if len(choices) > 0 {
+		text = fmt.Sprintf("%v\n%v", text, strings.Join(choices, "  "))
+	}
+	_, err := fmt.Fprintf(rt.out, "garcon: %v\n", strings.Replace(text, "\n", "\n        ", -1))
+	return timestamp, err
+}
+
+// setupRepl configures Garcon to be driven from the terminal against fake Maps and
+// delivery backends, so the whole order flow can be exercised offline
+func setupRepl(args []string) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	as := flags.String("as", "alice", "who you're talking to Garcon as")
+	others := flags.String("users", "bob,carol", "comma separated list of other people you can switch to")
+	restaurantsFile := flags.String("restaurants", "", "JSON file of restaurants to choose from, instead of pretending every restaurant exists")
+	flags.Parse(args)
+
+	names := []string{*as}
+	for _, n := range strings.Split(*others, ",") {
+		if n = strings.TrimSpace(n); len(n) > 0 && n != *as {
+			names = append(names, n)
+		}
+	}
+	transport = NewReplTransport(os.Stdin, os.Stdout, names)
+
+	g = NewGarcon()
+	g.SelfName = "garcon"
+	g.Interactive = true
+
+	patrons, err := transport.Patrons()
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.Patrons = make(map[string]Patron)
+	for _, p := range patrons {
+		g.Patrons[p.ID] = p
+	}
+	g.FindBotSlackID()
+	g.AllowedChannels, _ = transport.ChannelIDs(nil)
+
+	g.RestaurantFinder = NewGoogleRestaurantFinder(fakeMapsClient{})
+	if len(*restaurantsFile) > 0 {
+		g.RestaurantFinder, err = NewStaticRestaurantFinder(*restaurantsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	g.DeliveryProvider = newFakeDeliveryProvider()
+	g.OrderDestination = NewDeliverySpot("The Office", "1 Main St", "(555) 555-0000")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drainReplMessages(rt *ReplTransport) []Message {
+	messages := []Message{}
+	for m := range rt.Messages() {
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func TestReplTransportRelaysWhatsTyped(t *testing.T) {
+	in := bytes.NewReader([]byte("@garcon I'll have a taco\n\n/as bob\n/click confirm\n/garcon status\n/as nobody\n/garcon\n/quit\nnobody hears this\n"))
+	out := &bytes.Buffer{}
+	rt := NewReplTransport(in, out, []string{"alice", "bob"})
+	rt.Start()
+
+	assert.Equal(t, []Message{
+		Message{User: "U00000001", Channel: replChannel, Text: "<@UGARC0N00> I'll have a taco"},
+		Message{User: "U00000002", Channel: replChannel, Action: "confirm"},
+		Message{User: "U00000002", Channel: replChannel, Command: "status"},
+		Message{User: "U00000002", Channel: replChannel, Command: "help"},
+	}, drainReplMessages(rt))
+	assert.Contains(t, out.String(), "I don't know who nobody is, try /who\n")
+	assert.True(t, strings.HasSuffix(out.String(), "bob> "), "the prompt should follow whoever's talking")
+}
+
+func TestReplTransportCarriesOnInGarconsThread(t *testing.T) {
+	in := bytes.NewReader([]byte("@garcon I'll have a taco\n/click confirm\n"))
+	out := &bytes.Buffer{}
+	rt := NewReplTransport(in, out, []string{"alice"})
+
+	timestamp, err := rt.Send(OutgoingMessage{Channel: replChannel, Text: "Hi, @alice! Would you like to place an order?", StartsThread: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.000000", timestamp)
+	timestamp, _ = rt.Send(OutgoingMessage{
+		Channel: replChannel,
+		Text:    "Here's the order:\nAlice: 1x taco",
+		Buttons: []Choice{Choice{Text: "Confirm", Value: confirmAction}, Choice{Text: "Cancel", Value: cancelAction}},
+	})
+	assert.Equal(t, "2.000000", timestamp)
+	assert.Equal(t, "garcon: Hi, @alice! Would you like to place an order?\ngarcon: Here's the order:\n        Alice: 1x taco\n        [confirm] Confirm  [cancel] Cancel\n", out.String())
+
+	rt.Start()
+	messages := drainReplMessages(rt)
+	assert.Equal(t, 2, len(messages))
+	for _, m := range messages {
+		assert.Equal(t, "1.000000", m.ThreadTimestamp, "everything should be said in the thread Garcon started")
+	}
+}
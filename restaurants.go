@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"strings"
+
+	"golang.org/x/net/context"
+	"googlemaps.github.io/maps"
+)
+
+const maxRestaurantResults = 5
+
+// Restaurant is somewhere Garcon can order from
+type Restaurant struct {
+	PlaceID string   `json:"place_id"`
+	Name    string   `json:"name"`
+	Address string   `json:"address"`
+	Phone   string   `json:"phone"`
+	Hours   []string `json:"hours,omitempty"`
+	// Distance is how far away the restaurant is in meters, or zero if we don't know
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// distanceBetween returns the great-circle distance in meters between two points
+func distanceBetween(a, b maps.LatLng) float64 {
+	const earthRadius = 6371000 // in meters
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(b.Lat - a.Lat)
+	dLng := rad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rad(a.Lat))*math.Cos(rad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// RestaurantFinder is anything that can look up restaurants by name
+type RestaurantFinder interface {
+	// FindRestaurants returns the restaurants matching the query that can deliver to
+	// the given address, best matches first
+	FindRestaurants(query, near string) ([]Restaurant, error)
+}
+
+// mapsClient is the part of the Google Maps client GoogleRestaurantFinder relies on
+type mapsClient interface {
+	Geocode(ctx context.Context, r *maps.GeocodingRequest) ([]maps.GeocodingResult, error)
+	NearbySearch(ctx context.Context, r *maps.NearbySearchRequest) (maps.PlacesSearchResponse, error)
+	PlaceDetails(ctx context.Context, r *maps.PlaceDetailsRequest) (maps.PlaceDetailsResult, error)
+}
+
+// GoogleRestaurantFinder is a RestaurantFinder backed by the Google Places API
+type GoogleRestaurantFinder struct {
+	Client mapsClient
+	Radius uint // in meters
+}
+
+// NewGoogleRestaurantFinder constructs a GoogleRestaurantFinder
+func NewGoogleRestaurantFinder(c mapsClient) *GoogleRestaurantFinder {
+	return &GoogleRestaurantFinder{
+		Client: c,
+		Radius: 10000, // In meters, this is completely arbitrary
+	}
+}
+
+// FindRestaurants looks for open restaurants around the given address
+func (gf *GoogleRestaurantFinder) FindRestaurants(query, near string) ([]Restaurant, error) {
+	gr := &maps.GeocodingRequest{Address: near}
+	gresp, err := gf.Client.Geocode(context.TODO(), gr)
+	if err != nil {
+		return nil, fmt.Errorf("error geocoding location: %v", err)
+	}
+	if len(gresp) == 0 {
+		return nil, fmt.Errorf("unable to geocode %v", near)
+	}
+
+	nsr := &maps.NearbySearchRequest{
+		Location: &gresp[0].Geometry.Location,
+		Radius:   gf.Radius,
+		Keyword:  query,
+		Name:     query,
+		OpenNow:  true,
+		MinPrice: "0",
+		MaxPrice: "4",
+		Type:     "restaurant",
+	}
+	places, err := gf.Client.NearbySearch(context.TODO(), nsr)
+	if err != nil {
+		return nil, fmt.Errorf("error conducting search: %v", err)
+	}
+
+	restaurants := []Restaurant{}
+	for i, p := range places.Results {
+		if i == maxRestaurantResults {
+			break
+		}
+
+		r := Restaurant{
+			PlaceID:  p.PlaceID,
+			Name:     p.Name,
+			Address:  p.Vicinity,
+			Distance: distanceBetween(gresp[0].Geometry.Location, p.Geometry.Location),
+		}
+		details, err := gf.Client.PlaceDetails(context.TODO(), &maps.PlaceDetailsRequest{PlaceID: p.PlaceID})
+		if err != nil {
+			log.Printf("I wasn't able to get the details for %v, so I'll make do without them: %v", p.Name, err)
+		} else {
+			if len(details.FormattedAddress) > 0 {
+				r.Address = details.FormattedAddress
+			}
+			r.Phone = details.FormattedPhoneNumber
+			if details.OpeningHours != nil {
+				r.Hours = details.OpeningHours.WeekdayText
+			}
+		}
+		restaurants = append(restaurants, r)
+	}
+	return restaurants, nil
+}
+
+// StaticRestaurantFinder is a RestaurantFinder that only knows about a fixed list of
+// restaurants, for offices that keep a curated list or for testing offline
+type StaticRestaurantFinder struct {
+	Restaurants []Restaurant
+}
+
+// NewStaticRestaurantFinder constructs a StaticRestaurantFinder from a JSON file
+// containing a list of restaurants
+func NewStaticRestaurantFinder(path string) (*StaticRestaurantFinder, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &StaticRestaurantFinder{}
+	if err := json.Unmarshal(b, &sf.Restaurants); err != nil {
+		return nil, fmt.Errorf("unable to read restaurants from %v: %v", path, err)
+	}
+	return sf, nil
+}
+
+// FindRestaurants returns every restaurant whose name contains the query or is
+// contained by it, so both "chili's" and "the Chili's on Lamar" find a "Chili's"
+func (sf *StaticRestaurantFinder) FindRestaurants(query, near string) ([]Restaurant, error) {
+	q := cleanString(query)
+	restaurants := []Restaurant{}
+	for _, r := range sf.Restaurants {
+		name := cleanString(r.Name)
+		if len(name) > 0 && (strings.Contains(name, q) || strings.Contains(q, name)) {
+			restaurants = append(restaurants, r)
+		}
+	}
+	return restaurants, nil
+}
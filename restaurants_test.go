@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticRestaurantFinder(t *testing.T) {
+	f, err := ioutil.TempFile("", "restaurants")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`[
+		{"place_id": "chilis-lamar", "name": "Chili's", "address": "4511 N Lamar Blvd", "phone": "(512) 555-1234"},
+		{"place_id": "gary", "name": "Gary's Racoon Hut", "address": "123 Fake St", "hours": ["Monday: 11:00 AM – 2:00 PM"]}
+	]`)
+	f.Close()
+
+	sf, err := NewStaticRestaurantFinder(f.Name())
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	restaurants, err := sf.FindRestaurants("the Chili's on 45th & Lamar", "1 Main St")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(restaurants)) {
+		assert.Equal(t, "chilis-lamar", restaurants[0].PlaceID)
+		assert.Equal(t, "(512) 555-1234", restaurants[0].Phone)
+	}
+
+	restaurants, err = sf.FindRestaurants("racoon", "1 Main St")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(restaurants)) {
+		assert.Equal(t, "Gary's Racoon Hut", restaurants[0].Name)
+	}
+
+	restaurants, err = sf.FindRestaurants("Applebee's", "1 Main St")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(restaurants))
+}
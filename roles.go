@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Role is how much say someone has over orders
+type Role int
+
+// Each role can do everything the roles before it can
+const (
+	// ParticipantRole can add to orders other people started
+	ParticipantRole Role = iota
+	// OrdererRole can also start, confirm, send off and cancel orders
+	OrdererRole
+	// AdminRole can also step in on orders someone else started
+	AdminRole
+)
+
+var roleNames = map[string]Role{
+	"participant": ParticipantRole,
+	"orderer":     OrdererRole,
+	"admin":       AdminRole,
+}
+
+// whoCan describes everyone with at least the given role, for telling people who
+// they'd need to ask
+var whoCan = map[Role]string{
+	ParticipantRole: "anyone",
+	OrdererRole:     "orderers and admins",
+	AdminRole:       "admins",
+}
+
+// RoleOf returns the role of the given user. People can be given a role by their ID,
+// their name, or a group they're in, and get the highest role any of those has.
+// Anyone without one gets the default role.
+func (g *Garcon) RoleOf(user string) Role {
+	p := g.Patrons[user]
+	role, assigned := g.DefaultRole, false
+	for _, member := range append([]string{user, p.Name}, p.Groups...) {
+		if r, ok := g.Roles[strings.ToLower(member)]; ok && (!assigned || r > role) {
+			role, assigned = r, true
+		}
+	}
+	return role
+}
+
+// speaksFor returns whether the sender of a message gets a say over an order that
+// only its interlocutor would otherwise get a say over
+func (g *Garcon) speaksFor(s *Session, m Message) bool {
+	return m.User == s.InterlocutorID || g.RoleOf(m.User) >= AdminRole
+}
+
+// permitted returns whether the sender of a message has the role needed for what
+// they're trying to do
+func (g *Garcon) permitted(s *Session, m Message, mt string) bool {
+	required, ok := g.RolesRequired[s.Stage][mt]
+	return !ok || g.RoleOf(m.User) >= required
+}
+
+func (g *Garcon) roleRequiredResponse(s *Session, m Message, mt string) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	log.Printf("@%v tried to do something (%v) in the %v stage that they're not allowed to.", name, mt, s.Stage)
+	t := fmt.Sprintf("I'm sorry, @%v, you're not allowed to do that. Only %v can.", name, whoCan[g.RolesRequired[s.Stage][mt]])
+	return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleOf(t *testing.T) {
+	g, _, _ := returnGarconAndEmptyMessage()
+	g.Patrons["B0SSB0SS1"] = Patron{ID: "B0SSB0SS1", Name: "boss", Groups: []string{"S0FF1CE00", "office-managers"}}
+	g.Patrons["N3WH1RE01"] = Patron{ID: "N3WH1RE01", Name: "newbie"}
+	g.Roles = map[string]Role{
+		"office-managers": AdminRole,
+		"boss":            ParticipantRole,
+		"l0lwtfbbq":       OrdererRole,
+	}
+	g.DefaultRole = ParticipantRole
+
+	assert.Equal(t, AdminRole, g.RoleOf("B0SSB0SS1"), "the highest role someone's been given should win")
+	assert.Equal(t, OrdererRole, g.RoleOf("L0LWTFBBQ"))
+	assert.Equal(t, ParticipantRole, g.RoleOf("N3WH1RE01"))
+}
+
+func TestConfigAppliesRoles(t *testing.T) {
+	c := NewConfig()
+	g := NewGarcon()
+	c.Apply(g)
+	assert.Equal(t, OrdererRole, g.DefaultRole, "everyone should be an orderer when nobody's been given a role")
+
+	c.Roles.Admins = []string{"@Office-Managers"}
+	c.Roles.Orderers = []string{"alice", "office-managers"}
+	g = NewGarcon()
+	c.Apply(g)
+	assert.Equal(t, ParticipantRole, g.DefaultRole)
+	assert.Equal(t, map[string]Role{"office-managers": AdminRole, "alice": OrdererRole}, g.Roles)
+
+	c.Roles.Default = "chef"
+	assert.Contains(t, c.Validate().Error(), "roles.default")
+}
+
+func TestGarconOnlyLetsOrderersStartAndSendOrders(t *testing.T) {
+	g, s, m := returnGarconAndEmptyMessage()
+	g.DefaultRole = ParticipantRole
+
+	m.Text = "oh, garçon?"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, you're not allowed to do that. Only orderers and admins can.", messages[0].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+
+	s.Stage = "ordering"
+	m.Text = "<@G4RC0NB0T> I'll have a pickle"
+	g.RespondToMessage(m)
+	assert.Equal(t, []LineItem{LineItem{Quantity: 1, Name: "pickle"}}, s.Order["brainfart"], "participants should still be able to add to orders")
+
+	m.Text = "I think we're ready"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @brainfart, you're not allowed to do that. Only orderers and admins can.", messages[0].Text)
+	assert.Equal(t, "ordering", s.Stage)
+}
+
+func TestGarconLetsAdminsStepIn(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(599)
+	g.Patrons["B0SSB0SS1"] = Patron{ID: "B0SSB0SS1", Name: "boss"}
+	g.Roles["boss"] = AdminRole
+	g.RespondToMessage(Message{User: s.InterlocutorID, Channel: m.Channel, Text: "yes"})
+	assert.Equal(t, "approval", s.Stage)
+
+	m.User = "B0SSB0SS1"
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Session holds the state of a single group order. Garcon keeps one of these for
+// every channel (or thread) it's taking orders in, so several teams can order at once.
+type Session struct {
+	Key                 string
+	Channel             string
+	ThreadTimestamp     string
+	Stage               string
+	InterlocutorID      string
+	RequestedRestaurant string
+	ActualRestaurant    *Restaurant
+	Candidates          []Restaurant
+	Order               Order
+	Deadline            time.Time
+	RemindersSent       int
+	LastActivity        time.Time
+	DeliveryError       string
+	// LimitsOverridden is set when an admin lets the order go through in spite of the
+	// spending limits
+	LimitsOverridden bool
+	// Menu is the chosen restaurant's menu, if Garcon has one, and Clarifications are
+	// items people ordered that matched several things on it, by who ordered them
+	Menu           *Menu
+	Clarifications map[string]Clarification
+	// Quote is what delivering the order will cost, once it's been asked for
+	Quote *DeliveryQuote
+	// Delivery is the order's delivery, once it's been dispatched
+	Delivery          *Delivery
+	DeliveryCheckedAt time.Time
+	// History is what's happened to the most recent order. It's wiped when the next order
+	// starts, and copied into the order history once a delivery's over, since a thread's
+	// session is forgotten as soon as it's reset.
+	History []SessionEvent
+}
+
+// SessionEvent is something worth remembering that happened to an order
+type SessionEvent struct {
+	Time        time.Time
+	Description string
+}
+
+// NewSession constructs a fresh, uninitiated session for the given channel and thread
+func NewSession(channel, thread string) *Session {
+	s := &Session{
+		Key:             sessionKey(channel, thread),
+		Channel:         channel,
+		ThreadTimestamp: thread,
+	}
+	s.Reset()
+	return s
+}
+
+// Reset wipes the order state of the session, leaving where it lives untouched
+func (s *Session) Reset() {
+	s.Stage = "uninitiated"
+	s.InterlocutorID = ""
+	s.RequestedRestaurant = ""
+	s.Order = make(Order)
+	s.ActualRestaurant = &Restaurant{}
+	s.Candidates = nil
+	s.Menu = nil
+	s.Clarifications = nil
+	s.Deadline = time.Time{}
+	s.RemindersSent = 0
+	s.LastActivity = time.Time{}
+	s.DeliveryError = ""
+	s.LimitsOverridden = false
+	s.Quote = nil
+	s.Delivery = nil
+	s.DeliveryCheckedAt = time.Time{}
+}
+
+// Record adds an event to the session's history
+func (s *Session) Record(when time.Time, format string, args ...interface{}) {
+	s.History = append(s.History, SessionEvent{Time: when, Description: fmt.Sprintf(format, args...)})
+}
+
+func sessionKey(channel, thread string) string {
+	if len(thread) == 0 {
+		return channel
+	}
+	return channel + "/" + thread
+}
+
+// SessionManager keeps track of every session Garcon is running. If it has a Store,
+// sessions are snapshotted to it so they can be picked back up after a restart.
+type SessionManager struct {
+	Store SessionStore
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewSessionManager constructs an empty SessionManager
+func NewSessionManager() *SessionManager {
+	return &SessionManager{sessions: make(map[string]*Session)}
+}
+
+// SessionFor returns the session a message in the given channel and thread belongs to.
+// Messages in a thread with its own session go to that session, everything else goes
+// to the channel's session, which is created if it doesn't exist yet.
+func (sm *SessionManager) SessionFor(channel, thread string) *Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if len(thread) > 0 {
+		if s, ok := sm.sessions[sessionKey(channel, thread)]; ok {
+			return s
+		}
+	}
+
+	key := sessionKey(channel, "")
+	if s, ok := sm.sessions[key]; ok {
+		return s
+	}
+	s := NewSession(channel, "")
+	sm.sessions[key] = s
+	return s
+}
+
+// All returns every session currently being tracked
+func (sm *SessionManager) All() []*Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sessions := []*Session{}
+	for _, s := range sm.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// ActiveSessionIn returns the session for the order going on in a channel, for messages
+// like slash commands that don't say which thread they're about. If there are several,
+// whichever was most recently active wins, and if there are none it's the channel's own.
+func (sm *SessionManager) ActiveSessionIn(channel string) *Session {
+	sm.mu.Lock()
+	var latest *Session
+	for _, s := range sm.sessions {
+		if s.Channel == channel && s.Stage != "uninitiated" && (latest == nil || s.LastActivity.After(latest.LastActivity)) {
+			latest = s
+		}
+	}
+	sm.mu.Unlock()
+
+	if latest != nil {
+		return latest
+	}
+	return sm.SessionFor(channel, "")
+}
+
+// MoveToThread moves a channel's session into a thread, so the order carries on there
+// and the channel is free for another one
+func (sm *SessionManager) MoveToThread(s *Session, thread string) {
+	sm.mu.Lock()
+	oldKey := s.Key
+	if sm.sessions[oldKey] == s {
+		delete(sm.sessions, oldKey)
+	}
+	s.ThreadTimestamp = thread
+	s.Key = sessionKey(s.Channel, thread)
+	sm.sessions[s.Key] = s
+	sm.mu.Unlock()
+
+	if sm.Store != nil {
+		if err := sm.Store.Delete(oldKey); err != nil {
+			log.Printf("I wasn't able to delete the session for %v:\n\t%v\n", oldKey, err)
+		}
+	}
+	sm.Persist(s)
+}
+
+// Persist snapshots the session to the manager's store, if it has one. Sessions that
+// have gone back to being uninitiated have nothing worth keeping, so they're deleted,
+// and if they had a thread of their own they're forgotten entirely.
+func (sm *SessionManager) Persist(s *Session) {
+	if s.Stage == "uninitiated" && len(s.ThreadTimestamp) > 0 {
+		sm.mu.Lock()
+		if sm.sessions[s.Key] == s {
+			delete(sm.sessions, s.Key)
+		}
+		sm.mu.Unlock()
+	}
+	if sm.Store == nil {
+		return
+	}
+
+	var err error
+	if s.Stage == "uninitiated" {
+		err = sm.Store.Delete(s.Key)
+	} else {
+		err = sm.Store.Save(s)
+	}
+	if err != nil {
+		log.Printf("I wasn't able to save the session for %v:\n\t%v\n", s.Key, err)
+	}
+}
+
+// Restore loads every session from the manager's store, replacing any it already has
+// with the same key
+func (sm *SessionManager) Restore() error {
+	if sm.Store == nil {
+		return nil
+	}
+
+	sessions, err := sm.Store.Load()
+	if err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for _, s := range sessions {
+		if s.Order == nil {
+			s.Order = make(Order)
+		}
+		sm.sessions[s.Key] = s
+	}
+	return nil
+}
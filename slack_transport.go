@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/nlopes/slack"
+)
+
+// slackCallbackID identifies Garcon's interactive messages to Slack
+const slackCallbackID = "garcon"
+
+// maxRememberedThreads is how many of its own messages SlackTransport remembers the
+// threads of, so that reactions to them can be traced back to the right order
+const maxRememberedThreads = 500
+
+// maxRelayedMessages is how many messages SlackTransport will hold on to while Garcon's
+// busy, before whatever's relaying the next one has to wait
+const maxRelayedMessages = 100
+
+// SlackTransport is a Transport that listens to Slack over its real time messaging API,
+// and talks back through its web API
+type SlackTransport struct {
+	client   *slack.Client
+	rtm      *slack.RTM
+	messages chan Message
+
+	// relayed holds messages in the order they arrived, until Garcon's ready for them
+	relayed   chan Message
+	relayOnce sync.Once
+
+	mu sync.Mutex
+	// threads maps the timestamps of messages Garcon sent to the threads they were in
+	threads map[string]string
+	sent    []string
+}
+
+// NewSlackTransport constructs a SlackTransport that authenticates with the given token
+func NewSlackTransport(token string) *SlackTransport {
+	client := slack.New(token)
+	return &SlackTransport{
+		client:   client,
+		rtm:      client.NewRTM(),
+		messages: make(chan Message),
+		threads:  make(map[string]string),
+	}
+}
+
+func makeIDToUserMap(in []slack.User) map[string]slack.User {
+	users := make(map[string]slack.User)
+	for _, u := range in {
+		users[u.ID] = u
+	}
+	return users
+}
+
+// Patrons returns every user on the Slack team, along with the user groups they're in
+func (st *SlackTransport) Patrons() ([]Patron, error) {
+	users, err := st.client.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string][]string{}
+	userGroups, err := st.client.GetUserGroups(slack.GetUserGroupsOptionIncludeUsers(true))
+	if err != nil {
+		// not every team has user groups, so this isn't worth giving up over
+		log.Printf("I wasn't able to find out about the team's user groups:\n\t%v\n", err)
+	}
+	for _, ug := range userGroups {
+		for _, id := range ug.Users {
+			groups[id] = append(groups[id], ug.ID, ug.Handle)
+		}
+	}
+
+	patrons := []Patron{}
+	for _, u := range makeIDToUserMap(users) {
+		patrons = append(patrons, Patron{ID: u.ID, Name: u.Name, Groups: groups[u.ID]})
+	}
+	return patrons, nil
+}
+
+// ChannelIDs returns the IDs of the named Slack channels
+func (st *SlackTransport) ChannelIDs(names []string) ([]string, error) {
+	channels, err := st.client.GetChannels(true)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, ch := range channels {
+		if sliceContainsString(ch.Name, names) {
+			ids = append(ids, ch.ID)
+		}
+	}
+	return ids, nil
+}
+
+// Start connects to Slack's RTM API and starts relaying message events
+func (st *SlackTransport) Start() {
+	go st.rtm.ManageConnection()
+	go func() {
+		for msg := range st.rtm.IncomingEvents {
+			switch ev := msg.Data.(type) {
+
+			case *slack.MessageEvent:
+				st.relay(Message{
+					User:            ev.User,
+					Channel:         ev.Channel,
+					Timestamp:       ev.Timestamp,
+					ThreadTimestamp: ev.ThreadTimestamp,
+					Text:            ev.Text,
+				})
+
+			case *slack.ReactionAddedEvent:
+				st.relay(Message{
+					User:            ev.User,
+					Channel:         ev.Item.Channel,
+					Timestamp:       ev.EventTimestamp,
+					ThreadTimestamp: st.threadOf(ev.Item.Timestamp),
+					Reaction:        ev.Reaction,
+				})
+
+			case *slack.RTMError:
+				log.Printf("I encountered a Slack related error: %s\n", ev.Error())
+			}
+		}
+	}()
+}
+
+// Messages delivers messages said in any channel Garcon is in
+func (st *SlackTransport) Messages() <-chan Message {
+	return st.messages
+}
+
+// Send posts a message to a Slack channel, or a thread within it
+func (st *SlackTransport) Send(m OutgoingMessage) (string, error) {
+	options := []slack.MsgOption{slack.MsgOptionText(m.Text, false)}
+	if len(m.ThreadTimestamp) > 0 {
+		options = append(options, slack.MsgOptionTS(m.ThreadTimestamp))
+	}
+	if len(m.Buttons) > 0 || len(m.Options) > 0 {
+		options = append(options, slack.MsgOptionAttachments(choicesAttachment(m)))
+	}
+
+	_, timestamp, err := st.client.PostMessage(m.Channel, options...)
+	if err != nil {
+		return "", err
+	}
+	st.rememberThread(timestamp, m.ThreadTimestamp)
+	return timestamp, nil
+}
+
+func (st *SlackTransport) rememberThread(timestamp, thread string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.threads[timestamp] = thread
+	st.sent = append(st.sent, timestamp)
+	if len(st.sent) > maxRememberedThreads {
+		delete(st.threads, st.sent[0])
+		st.sent = st.sent[1:]
+	}
+}
+
+// threadOf returns the thread one of Garcon's messages was sent in, if any
+func (st *SlackTransport) threadOf(timestamp string) string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.threads[timestamp]
+}
+
+// choicesAttachment turns a message's choices into the attachment Slack shows them with
+func choicesAttachment(m OutgoingMessage) slack.Attachment {
+	a := slack.Attachment{
+		Fallback:   m.Text,
+		CallbackID: slackCallbackID,
+	}
+	if len(m.Options) > 0 {
+		menu := slack.AttachmentAction{Name: "choice", Text: "Choose one...", Type: "select"}
+		for _, o := range m.Options {
+			menu.Options = append(menu.Options, slack.AttachmentActionOption{Text: o.Text, Value: o.Value})
+		}
+		a.Actions = append(a.Actions, menu)
+	}
+	for _, b := range m.Buttons {
+		a.Actions = append(a.Actions, slack.AttachmentAction{Name: "choice", Text: b.Text, Type: "button", Value: b.Value, Style: b.Style})
+	}
+	return a
+}
+
+// readVerifiedRequest returns the body of a request from Slack, after making sure it
+// really was Slack that sent it
+func readVerifiedRequest(r *http.Request, signingSecret string) ([]byte, error) {
+	verifier, err := slack.NewSecretsVerifier(r.Header, signingSecret)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	verifier.Write(body)
+	if err := verifier.Ensure(); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// InteractionHandler handles the requests Slack makes when someone clicks one of
+// Garcon's buttons or menus, relaying each click as a Message
+func (st *SlackTransport) InteractionHandler(signingSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := readVerifiedRequest(r, signingSecret)
+		if err != nil {
+			log.Printf("I received an interaction that I couldn't verify came from Slack: %v\n", err)
+			http.Error(w, "unverified request", http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "malformed request", http.StatusBadRequest)
+			return
+		}
+
+		var callback slack.InteractionCallback
+		if err := json.NewDecoder(bytes.NewBufferString(form.Get("payload"))).Decode(&callback); err != nil {
+			log.Printf("I couldn't make sense of an interaction from Slack: %v\n", err)
+			http.Error(w, "malformed payload", http.StatusBadRequest)
+			return
+		}
+		if callback.CallbackID != slackCallbackID || len(callback.ActionCallback.AttachmentActions) == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		action := callback.ActionCallback.AttachmentActions[0]
+		value := action.Value
+		if len(action.SelectedOptions) > 0 {
+			value = action.SelectedOptions[0].Value
+		}
+		st.relay(Message{
+			User:            callback.User.ID,
+			Channel:         callback.Channel.ID,
+			Timestamp:       callback.ActionTs,
+			ThreadTimestamp: callback.OriginalMessage.ThreadTimestamp,
+			Action:          value,
+		})
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// SlashCommandHandler handles the requests Slack makes when someone uses the /garcon
+// command, relaying each one as a Message
+func (st *SlackTransport) SlashCommandHandler(signingSecret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := readVerifiedRequest(r, signingSecret)
+		if err != nil {
+			log.Printf("I received a slash command that I couldn't verify came from Slack: %v\n", err)
+			http.Error(w, "unverified request", http.StatusUnauthorized)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		command, err := slack.SlashCommandParse(r)
+		if err != nil {
+			http.Error(w, "malformed request", http.StatusBadRequest)
+			return
+		}
+
+		text := strings.TrimSpace(command.Text)
+		if len(text) == 0 {
+			text = "help"
+		}
+		st.relay(Message{
+			User:    command.UserID,
+			Channel: command.ChannelID,
+			Command: text,
+		})
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// relay passes on a message without holding up whoever it came from, since Slack gives
+// up on requests that take more than three seconds, and Garcon might be busy with
+// something else. Everything goes through the same queue, so Garcon hears it all in the
+// order it arrived.
+func (st *SlackTransport) relay(m Message) {
+	st.relayOnce.Do(func() {
+		st.relayed = make(chan Message, maxRelayedMessages)
+		go func() {
+			for m := range st.relayed {
+				st.messages <- m
+			}
+		}()
+	})
+	st.relayed <- m
+}
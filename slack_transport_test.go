@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signedSlackRequest(path, body, secret string) *http.Request {
+	timestamp := fmt.Sprintf("%v", time.Now().Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%v:%v", timestamp, body)
+
+	r := httptest.NewRequest("POST", path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestSlackInteractionHandlerRelaysClicks(t *testing.T) {
+	st := &SlackTransport{messages: make(chan Message, 1)}
+	handler := st.InteractionHandler("shhh")
+
+	payload := `{"type":"interactive_message","callback_id":"garcon","user":{"id":"L0LWTFBBQ"},"channel":{"id":"C0FFEE"},"action_ts":"1234.5678","actions":[{"name":"choice","type":"select","selected_options":[{"value":"2"}]}]}`
+	body := url.Values{"payload": []string{payload}}.Encode()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, signedSlackRequest("/slack/interactions", body, "shhh"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Message{User: "L0LWTFBBQ", Channel: "C0FFEE", Timestamp: "1234.5678", Action: "2"}, <-st.messages)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, signedSlackRequest("/slack/interactions", body, "not the secret"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	select {
+	case m := <-st.messages:
+		t.Errorf("an unverified click shouldn't be relayed, but got %v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSlackSlashCommandHandlerRelaysCommands(t *testing.T) {
+	st := &SlackTransport{messages: make(chan Message, 1)}
+	handler := st.SlashCommandHandler("shhh")
+
+	body := url.Values{"command": []string{"/garcon"}, "text": []string{"add a banana"}, "user_id": []string{"L0LWTFBBQ"}, "channel_id": []string{"C0FFEE"}}.Encode()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, signedSlackRequest("/slack/commands", body, "shhh"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Message{User: "L0LWTFBBQ", Channel: "C0FFEE", Command: "add a banana"}, <-st.messages)
+}
+
+func TestSlackSlashCommandHandlerDoesntWaitOnGarcon(t *testing.T) {
+	// nobody's reading messages, like when Garcon's busy placing an order
+	st := &SlackTransport{messages: make(chan Message)}
+	handler := st.SlashCommandHandler("shhh")
+
+	body := url.Values{"command": []string{"/garcon"}, "text": []string{"status"}, "user_id": []string{"L0LWTFBBQ"}, "channel_id": []string{"C0FFEE"}}.Encode()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, signedSlackRequest("/slack/commands", body, "shhh"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Message{User: "L0LWTFBBQ", Channel: "C0FFEE", Command: "status"}, <-st.messages)
+}
+
+func TestSlackTransportRelaysMessagesInOrder(t *testing.T) {
+	// nobody's reading messages yet, so everything has to wait its turn
+	st := &SlackTransport{messages: make(chan Message)}
+	handler := st.SlashCommandHandler("shhh")
+
+	for _, text := range []string{"add a banana", "status", "go away"} {
+		body := url.Values{"command": []string{"/garcon"}, "text": []string{text}, "user_id": []string{"L0LWTFBBQ"}, "channel_id": []string{"C0FFEE"}}.Encode()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, signedSlackRequest("/slack/commands", body, "shhh"))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	for _, text := range []string{"add a banana", "status", "go away"} {
+		select {
+		case m := <-st.messages:
+			assert.Equal(t, text, m.Command)
+		case <-time.After(time.Second):
+			t.Fatalf("%q was never relayed", text)
+		}
+	}
+}
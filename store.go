@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SessionStore is somewhere Garcon can stash its sessions so that an in-flight
+// order survives the bot being restarted
+type SessionStore interface {
+	Save(s *Session) error
+	Delete(key string) error
+	Load() ([]*Session, error)
+}
+
+// FileSessionStore is a SessionStore that keeps each session as a JSON file in a
+// local directory
+type FileSessionStore struct {
+	Dir string
+}
+
+// NewFileSessionStore constructs a FileSessionStore, creating its directory if need be
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create session directory %v: %v", dir, err)
+	}
+	return &FileSessionStore{Dir: dir}, nil
+}
+
+func (fs *FileSessionStore) pathFor(key string) string {
+	return filepath.Join(fs.Dir, url.PathEscape(key)+".json")
+}
+
+// Save writes the session to disk, replacing whatever was there before
+func (fs *FileSessionStore) Save(s *Session) error {
+	return saveJSON(fs.pathFor(s.Key), s)
+}
+
+// Delete removes a session from disk. Deleting a session that was never saved is fine.
+func (fs *FileSessionStore) Delete(key string) error {
+	err := os.Remove(fs.pathFor(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Load reads every session saved in the store's directory
+func (fs *FileSessionStore) Load() ([]*Session, error) {
+	files, err := ioutil.ReadDir(fs.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*Session{}
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(fs.Dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s := &Session{}
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, fmt.Errorf("unable to read session file %v: %v", f.Name(), err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// saveJSON writes v to the given path as JSON, replacing whatever was there before
+func saveJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash mid-write can't leave us with half a file
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadJSON reads the JSON file at the given path into v. A file that doesn't exist
+// yet leaves v as it was.
+func loadJSON(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("unable to read %v: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSessionStoreRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "garcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSession("whocares", "1234.5678")
+	s.Stage = "ordering"
+	s.InterlocutorID = "L0LWTFBBQ"
+	s.Order.Add("brainfart", LineItem{Quantity: 1, Name: "peach melba", Notes: "extra peach"})
+	assert.Nil(t, store.Save(s))
+
+	sm := NewSessionManager()
+	sm.Store = store
+	assert.Nil(t, sm.Restore())
+	assert.Equal(t, []*Session{s}, sm.All())
+
+	assert.Nil(t, store.Delete(s.Key))
+	assert.Nil(t, store.Delete(s.Key))
+	sessions, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(sessions))
+}
+
+func TestGarconPersistsSessionsOnTransition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "garcon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _, m := returnGarconAndEmptyMessage()
+	g.Sessions.Store = store
+	m.Text = "oh, garçon?"
+	g.RespondToMessage(m)
+
+	sessions, err := store.Load()
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(sessions)) {
+		assert.Equal(t, "prompted", sessions[0].Stage)
+		assert.Equal(t, m.User, sessions[0].InterlocutorID)
+	}
+}
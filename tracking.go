@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	deliveryStatusRequestPattern = "where('s| is| are) (our|my|the) (food|order|delivery|lunch|dinner)"
+	cancelDeliveryPattern        = "^" + atGarconPattern + "(please )?(cancel|call off|stop) (the|our) (delivery|order)"
+	collectedPattern             = "^" + atGarconPattern + "(I|we)('ve| have)? (picked up|got|grabbed) (the|our) (food|order|lunch|dinner)"
+)
+
+// deliveryStatus is what Garcon has to say about a delivery in a particular state
+type deliveryStatus struct {
+	// update is announced when a delivery changes to this status, and is formatted with
+	// the name of the restaurant
+	update string
+	// description finishes the sentence "our order from <restaurant> is..."
+	description string
+	// finished statuses are the last we'll hear about a delivery
+	finished bool
+	// cancelable statuses are ones a delivery can still be called off from
+	cancelable bool
+}
+
+var deliveryStatuses = map[string]deliveryStatus{
+	"pending": deliveryStatus{
+		update:      "A courier is being found for our order from %v.",
+		description: "waiting on a courier",
+		cancelable:  true,
+	},
+	"pickup": deliveryStatus{
+		update:      "Someone's on their way to %v to pick up our order.",
+		description: "waiting to be picked up",
+		cancelable:  true,
+	},
+	"pickup_complete": deliveryStatus{
+		update:      "Our order's been picked up from %v, and it's on its way!",
+		description: "on its way",
+	},
+	"dropoff": deliveryStatus{
+		update:      "The courier with our order from %v is almost here!",
+		description: "almost here",
+	},
+	"delivered": deliveryStatus{
+		update:      "Our order from %v has arrived. Enjoy!",
+		description: "here",
+		finished:    true,
+	},
+	"canceled": deliveryStatus{
+		update:      "The delivery from %v was canceled.",
+		description: "not coming, because the delivery was canceled",
+		finished:    true,
+	},
+	"returned": deliveryStatus{
+		update:      "The courier couldn't finish the delivery from %v, so it's being returned.",
+		description: "being returned to the restaurant",
+		finished:    true,
+	},
+}
+
+func describeDeliveryStatus(status string) deliveryStatus {
+	if ds, ok := deliveryStatuses[status]; ok {
+		return ds
+	}
+	return deliveryStatus{
+		update:      fmt.Sprintf("The delivery from %%v is now \"%v\".", status),
+		description: fmt.Sprintf("\"%v\"", status),
+	}
+}
+
+// trackDelivery holds on to a delivery that's just been dispatched, so Garcon can keep
+// everyone posted on how it's going
+func (g *Garcon) trackDelivery(s *Session, d *Delivery) {
+	s.Record(g.now(), "the order was sent off as delivery %v", d.ID)
+	s.Delivery = d
+	s.DeliveryCheckedAt = g.now()
+	s.Stage = "dispatched"
+}
+
+// deliveryUpdated takes in the latest on the session's delivery, announcing it if the
+// delivery's moved along. Once it's finished, the session's done with.
+func (g *Garcon) deliveryUpdated(s *Session, d *Delivery, now time.Time) []OutgoingMessage {
+	s.DeliveryCheckedAt = now
+	previous := s.Delivery.Status
+	s.Delivery = d
+	if d.Status == previous {
+		return nil
+	}
+
+	log.Printf("The delivery for %v went from %v to %v.", s.Key, previous, d.Status)
+	s.Record(now, "the delivery went from %v to %v", previous, d.Status)
+	s.LastActivity = now
+	ds := describeDeliveryStatus(d.Status)
+	// the end of a delivery is news for the whole channel, not just the order's thread
+	update := OutgoingMessage{Channel: s.Channel, Text: fmt.Sprintf(ds.update, s.ActualRestaurant.Name), InChannel: ds.finished}
+	if ds.finished {
+		g.orderFinished(s, d, now)
+		s.Reset()
+	}
+	return []OutgoingMessage{update}
+}
+
+// refreshDelivery asks the delivery provider how the session's delivery is going
+func (g *Garcon) refreshDelivery(s *Session, now time.Time) []OutgoingMessage {
+	if s.Delivery.Untracked {
+		// nobody knows any more than we do
+		s.DeliveryCheckedAt = now
+		return nil
+	}
+	d, err := g.DeliveryProvider.DeliveryStatus(s.Delivery.ID)
+	if err != nil {
+		log.Printf("I wasn't able to check on the delivery for %v:\n\t%v\n", s.Key, err)
+		s.DeliveryCheckedAt = now
+		return nil
+	}
+	return g.deliveryUpdated(s, d, now)
+}
+
+// DeliveryUpdated lets Garcon know about a change to a delivery it didn't ask about,
+// such as one a provider sent by webhook
+func (g *Garcon) DeliveryUpdated(d *Delivery) []OutgoingMessage {
+	for _, s := range g.Sessions.All() {
+		if s.Stage == "dispatched" && s.Delivery.ID == d.ID {
+			responses := about(s, g.deliveryUpdated(s, d, g.now()))
+			g.Sessions.Persist(s)
+			return responses
+		}
+	}
+	log.Printf("I heard about delivery %v, but it isn't one I'm keeping track of.", d.ID)
+	return nil
+}
+
+func (g *Garcon) deliveryStatusResponse(s *Session, m Message) []OutgoingMessage {
+	restaurant := s.ActualRestaurant.Name
+	// anything that's changed since we last checked comes first
+	responses := g.refreshDelivery(s, g.now())
+	if s.Stage != "dispatched" {
+		// it's finished, and the update says all there is to say
+		return responses
+	}
+
+	t := fmt.Sprintf("Our order from %v is %v.", restaurant, describeDeliveryStatus(s.Delivery.Status).description)
+	if !s.Delivery.DropoffETA.IsZero() {
+		t = fmt.Sprintf("%v It should be here around %v, %v from now.", t, s.Delivery.DropoffETA.Format("3:04 PM"), describeDuration(s.Delivery.DropoffETA.Sub(g.now())))
+	}
+	if len(s.Delivery.TrackingURL) > 0 {
+		t = fmt.Sprintf("%v You can follow along at %v", t, s.Delivery.TrackingURL)
+	}
+	return append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+}
+
+// cancelDelivery calls off a dispatched delivery, as long as it hasn't gone too far
+// to be called off
+func (g *Garcon) cancelDelivery(s *Session, m Message) []OutgoingMessage {
+	now := g.now()
+	name := g.Patrons[m.User].Name
+	restaurant := s.ActualRestaurant.Name
+	// whatever's changed since we last checked comes first, since it might be why the
+	// delivery can't be canceled
+	responses := g.refreshDelivery(s, now)
+	if s.Stage != "dispatched" {
+		t := fmt.Sprintf("I'm sorry, @%v, that delivery's already over.", name)
+		return append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+	}
+
+	ds := describeDeliveryStatus(s.Delivery.Status)
+	if !ds.cancelable {
+		s.Record(now, "%v asked to cancel the delivery, but it was already %v", name, ds.description)
+		t := fmt.Sprintf("I'm sorry, @%v, it's too late to cancel the delivery. Our order from %v is already %v.", name, restaurant, ds.description)
+		return append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+	}
+
+	var canceled *Delivery
+	err := withRetries(g.DeliveryAttempts, g.DeliveryBackoff, g.DeliveryTimeout, func() (err error) {
+		canceled, err = g.DeliveryProvider.CancelDelivery(s.Delivery.ID)
+		return
+	})
+	if err != nil {
+		log.Printf("I wasn't able to cancel the delivery for %v:\n\t%v\n", s.Key, err)
+		s.Record(now, "%v asked to cancel the delivery, but it couldn't be canceled: %v", name, err)
+		t := fmt.Sprintf("I'm sorry, @%v, I wasn't able to cancel the delivery: %v", name, err)
+		return append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+	}
+
+	// only the cancellation fee ends up being spent
+	g.spend(s, canceled.Fee-g.orderCost(s))
+	g.forgiveDebts(s, canceled.Fee)
+
+	t := fmt.Sprintf("Okay, I've canceled the delivery from %v.", restaurant)
+	if canceled.Fee > 0 {
+		t = fmt.Sprintf("%v There was a cancellation fee of %v.", t, describeMoney(canceled.Fee, canceled.Currency))
+		s.Record(now, "%v canceled the delivery, with a fee of %v", name, describeMoney(canceled.Fee, canceled.Currency))
+	} else {
+		s.Record(now, "%v canceled the delivery", name)
+	}
+	g.orderFinished(s, canceled, now)
+	s.Reset()
+
+	responses = append(responses, OutgoingMessage{Channel: m.Channel, Text: t})
+	if len(s.ThreadTimestamp) > 0 {
+		t = fmt.Sprintf("@%v canceled the order from %v.", name, restaurant)
+		responses = append(responses, OutgoingMessage{Channel: m.Channel, Text: t, InChannel: true})
+	}
+	return responses
+}
+
+// collected finishes off a delivery someone on the team went to get, since nobody else
+// is going to tell us it's arrived
+func (g *Garcon) collected(s *Session, m Message) []OutgoingMessage {
+	name := g.Patrons[m.User].Name
+	if !s.Delivery.Untracked {
+		t := fmt.Sprintf("@%v, a courier's bringing this order, so I'll hear about it when it gets here.", name)
+		return []OutgoingMessage{OutgoingMessage{Channel: m.Channel, Text: t}}
+	}
+
+	s.Record(g.now(), "%v picked up the order", name)
+	d := *s.Delivery
+	d.Status = "delivered"
+	return g.deliveryUpdated(s, &d, g.now())
+}
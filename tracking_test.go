@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scriptedDeliveryProvider reports whatever status it's been told to
+type scriptedDeliveryProvider struct {
+	fakeDeliveryProvider
+	status string
+}
+
+func (p *scriptedDeliveryProvider) DeliveryStatus(id string) (*Delivery, error) {
+	return &Delivery{ID: id, Status: p.status, TrackingURL: "https://example.com/track"}, nil
+}
+
+func returnGarconWithDispatchedOrder() (*Garcon, *Session, Message, *scriptedDeliveryProvider) {
+	g, s, m := returnGarconAndEmptyMessage()
+	provider := &scriptedDeliveryProvider{newFakeDeliveryProvider(), "pending"}
+	g.DeliveryProvider = provider
+	s.ActualRestaurant = &Restaurant{Name: "Gary's Racoon Hut"}
+	g.trackDelivery(s, &Delivery{ID: "del_1", Status: "pending"})
+	return g, s, m, provider
+}
+
+func TestGarconPostsDeliveryUpdates(t *testing.T) {
+	g, s, _, provider := returnGarconWithDispatchedOrder()
+	now := s.DeliveryCheckedAt
+
+	assert.Equal(t, 0, len(g.Tick(now.Add(time.Minute))), "nothing's changed, so there's nothing to say")
+
+	provider.status = "pickup_complete"
+	assert.Equal(t, 0, len(g.Tick(now.Add(90*time.Second))), "it's too soon to check again")
+	messages := g.Tick(now.Add(2 * time.Minute))
+	assert.Equal(t, "Our order's been picked up from Gary's Racoon Hut, and it's on its way!", messages[0].Text)
+	assert.Equal(t, "dispatched", s.Stage)
+
+	provider.status = "delivered"
+	messages = g.Tick(now.Add(3 * time.Minute))
+	assert.Equal(t, "Our order from Gary's Racoon Hut has arrived. Enjoy!", messages[0].Text)
+	assert.True(t, messages[0].InChannel)
+	assert.Equal(t, "uninitiated", s.Stage)
+}
+
+func TestGarconSaysWhereTheFoodIs(t *testing.T) {
+	g, s, m, provider := returnGarconWithDispatchedOrder()
+	provider.status = "dropoff"
+	s.Delivery.DropoffETA = g.now().Add(5 * time.Minute)
+
+	m.Text = "<@G4RC0NB0T>, where's our food?"
+	messages := g.RespondToMessage(m)
+	if assert.Equal(t, 2, len(messages)) {
+		assert.Equal(t, "The courier with our order from Gary's Racoon Hut is almost here!", messages[0].Text, "what's changed should come first")
+		assert.Equal(t, "Our order from Gary's Racoon Hut is almost here. You can follow along at https://example.com/track", messages[1].Text)
+	}
+
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, 1, len(messages), "nothing's changed since")
+
+	provider.status = "delivered"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Our order from Gary's Racoon Hut has arrived. Enjoy!", messages[0].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+}
+
+func (p *scriptedDeliveryProvider) CancelDelivery(id string) (*Delivery, error) {
+	p.status = "canceled"
+	return &Delivery{ID: id, Status: p.status, Fee: 250, Currency: "usd"}, nil
+}
+
+func TestGarconCancelsDeliveries(t *testing.T) {
+	g, s, m, _ := returnGarconAwaitingApproval(600)
+	g.Patrons["SOMEJERK"] = Patron{ID: "SOMEJERK", Name: "whocares"}
+	g.Sessions.MoveToThread(s, "1234.5678")
+	m.ThreadTimestamp = "1234.5678"
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+	assert.Contains(t, g.Sessions.All(), s)
+	provider := &scriptedDeliveryProvider{newFakeDeliveryProvider(), "pickup"}
+	g.DeliveryProvider = provider
+
+	m.User = "SOMEJERK"
+	m.Text = "<@G4RC0NB0T>, cancel the delivery"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "I'm sorry, @whocares, only @brainfart can do that for this order.", messages[0].Text)
+
+	m.User = s.InterlocutorID
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Someone's on their way to Gary's Racoon Hut to pick up our order.", messages[0].Text)
+	assert.Equal(t, "Okay, I've canceled the delivery from Gary's Racoon Hut. There was a cancellation fee of 2.50 USD.", messages[1].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+	for _, other := range g.Sessions.All() {
+		assert.NotEqual(t, s, other, "the thread's session should be gone")
+	}
+
+	// the session's forgotten, but the order history isn't
+	if assert.Equal(t, 1, len(g.OrderHistory.Orders)) {
+		r := g.OrderHistory.Orders[0]
+		assert.Equal(t, "canceled", r.Status)
+		history := []string{}
+		for _, event := range r.Events {
+			history = append(history, event.Description)
+		}
+		assert.Equal(t, []string{
+			"the order was sent off as delivery " + r.DeliveryID,
+			"the delivery went from pending to pickup",
+			"brainfart canceled the delivery, with a fee of 2.50 USD",
+		}, history)
+	}
+}
+
+func TestGarconWontCancelDeliveriesOnTheirWay(t *testing.T) {
+	g, s, m, provider := returnGarconWithDispatchedOrder()
+	provider.status = "pickup_complete"
+
+	m.Text = "<@G4RC0NB0T>, cancel the delivery"
+	messages := g.RespondToMessage(m)
+	if assert.Equal(t, 2, len(messages)) {
+		assert.Equal(t, "Our order's been picked up from Gary's Racoon Hut, and it's on its way!", messages[0].Text)
+		assert.Equal(t, "I'm sorry, @brainfart, it's too late to cancel the delivery. Our order from Gary's Racoon Hut is already on its way.", messages[1].Text)
+	}
+	assert.Equal(t, "dispatched", s.Stage)
+	assert.Equal(t, "brainfart asked to cancel the delivery, but it was already on its way", s.History[len(s.History)-1].Description)
+}
+
+func TestGarconFinishesPickupsWhenTold(t *testing.T) {
+	g, s, m, now := returnGarconAwaitingApproval(0)
+	g.DeliveryProvider = NewPickupProvider()
+	m.Text = "yes"
+	g.RespondToMessage(m)
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "dispatched", s.Stage)
+	assert.Equal(t, "Somebody needs to go pick it up! Say \"@garcon, we picked up the food\" once it's here.", messages[len(messages)-1].Text)
+
+	// pickups don't go anywhere on their own, even after a restart's forgotten about them
+	g.DeliveryProvider = NewPickupProvider()
+	assert.Empty(t, g.Tick(now.Add(10*time.Minute)))
+	m.Text = "<@G4RC0NB0T>, where's our food?"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Our order from Gary's Racoon Hut is waiting to be picked up.", messages[0].Text)
+
+	m.Text = "<@G4RC0NB0T>, we picked up the food"
+	messages = g.RespondToMessage(m)
+	assert.Equal(t, "Our order from Gary's Racoon Hut has arrived. Enjoy!", messages[0].Text)
+	assert.Equal(t, "uninitiated", s.Stage)
+	assert.Equal(t, "delivered", g.OrderHistory.Orders[0].Status)
+}
+
+func TestGarconLeavesCourierDeliveriesToTheCourier(t *testing.T) {
+	g, s, m, _ := returnGarconWithDispatchedOrder()
+	m.Text = "<@G4RC0NB0T>, I got the food"
+	messages := g.RespondToMessage(m)
+	assert.Equal(t, "@brainfart, a courier's bringing this order, so I'll hear about it when it gets here.", messages[0].Text)
+	assert.Equal(t, "dispatched", s.Stage)
+}
@@ -0,0 +1,73 @@
+package main
+
+// Message is something said in a channel Garcon can hear, independent of whatever
+// chat system it was said in
+type Message struct {
+	User            string
+	Channel         string
+	Timestamp       string
+	ThreadTimestamp string
+	Text            string
+	// Reaction is the name of the emoji someone reacted with, when the message is a reaction
+	Reaction string
+	// Action is the value of the Choice someone clicked, when the message is a click
+	Action string
+	// Command is everything after "/garcon", when the message is a slash command
+	Command string
+}
+
+// OutgoingMessage is something Garcon would like to say in a channel
+type OutgoingMessage struct {
+	Channel         string
+	ThreadTimestamp string
+	Text            string
+	// StartsThread marks the message the rest of an order's conversation should be
+	// threaded under
+	StartsThread bool
+	// InChannel messages are posted to the channel itself, even when the order they're
+	// about has a thread of its own
+	InChannel bool
+	// Buttons and Options are choices to offer alongside the text, for chat systems
+	// that can show them. Buttons are shown side by side, Options as a menu.
+	Buttons []Choice
+	Options []Choice
+
+	// session is the order the message is about, so the message can follow that order
+	// into its thread
+	session *Session
+}
+
+// Choice is something people can click rather than type. Clicking it comes back to
+// Garcon as a Message with the choice's Value as its Action.
+type Choice struct {
+	Text  string
+	Value string
+	// Style is "primary" or "danger" for choices that should stand out, if the chat
+	// system can show that
+	Style string
+}
+
+// Patron is someone Garcon might take an order from
+type Patron struct {
+	ID   string
+	Name string
+	// Groups are the IDs and names of the groups they're in, for chat systems that
+	// have groups
+	Groups []string
+}
+
+// Transport is how Garcon talks to a chat system. Implementations translate whatever
+// the chat system speaks to and from Garcon's own message types.
+type Transport interface {
+	// Patrons returns everyone Garcon could possibly talk to
+	Patrons() ([]Patron, error)
+	// ChannelIDs converts channel names into the IDs messages will be addressed with
+	ChannelIDs(names []string) ([]string, error)
+	// Start connects to the chat system and begins delivering messages
+	Start()
+	// Messages delivers incoming messages once the transport has been started
+	Messages() <-chan Message
+	// Send says something in a channel, returning the timestamp of what it said so
+	// that threads can be started from it
+	Send(m OutgoingMessage) (string, error)
+}